@@ -11,6 +11,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position //start of the node's source span
+	End() token.Position //position just past the node's source span
 }
 
 type Statement interface {
@@ -28,35 +30,47 @@ type Program struct {
 }
 
 type LetStatement struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
+	Token  token.Token
+	Name   *Identifier
+	Value  Expression
+	EndPos token.Position
 }
 
 type ReturnStatement struct {
-	Token token.Token
-	Value Expression
+	Token  token.Token
+	Value  Expression
+	EndPos token.Position
 }
 
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
+	EndPos     token.Position
 }
 
 type Identifier struct {
-	Token token.Token
-	Value string
+	Token  token.Token
+	Value  string
+	EndPos token.Position
 }
 
 type IntegerLiteral struct {
-	Token token.Token
-	Value int64
+	Token  token.Token
+	Value  int64
+	EndPos token.Position
+}
+
+type FloatLiteral struct {
+	Token  token.Token
+	Value  float64
+	EndPos token.Position
 }
 
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
 	Right    Expression
+	EndPos   token.Position
 }
 
 type InfixExpression struct {
@@ -64,11 +78,13 @@ type InfixExpression struct {
 	Operator string
 	Left     Expression
 	Right    Expression
+	EndPos   token.Position
 }
 
 type Boolean struct {
-	Token token.Token
-	Value bool
+	Token  token.Token
+	Value  bool
+	EndPos token.Position
 }
 
 type IfExpression struct {
@@ -76,44 +92,105 @@ type IfExpression struct {
 	Condition   Expression
 	Consequence *BlockStatement
 	Alternative *BlockStatement
+	EndPos      token.Position
+}
+
+//TryExpression evaluates Body, and if that raises an error evaluates Handler instead
+//with the error bound to Parameter, when one is given
+type TryExpression struct {
+	Token     token.Token
+	Body      *BlockStatement
+	Parameter *Identifier
+	Handler   *BlockStatement
+	EndPos    token.Position
+}
+
+//AssignExpression rebinds an existing variable or updates an array/hash element.
+//Operator is "be"/"=" for plain assignment or a compound operator such as "+="
+type AssignExpression struct {
+	Token    token.Token
+	Target   Expression //*Identifier or *IndexExpression
+	Operator string
+	Value    Expression
+	EndPos   token.Position
 }
 
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	EndPos     token.Position
 }
 
 type FunctionLiteral struct {
 	Token      token.Token
+	Name       string //the variable a let statement binds the function to, if any
 	Parameters []*Identifier
+	Defaults   []Expression //the default value of each parameter, nil for parameters that must be passed
+	Rest       *Identifier  //collects any arguments past the named parameters
 	Body       *BlockStatement
+	EndPos     token.Position
 }
 
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	EndPos    token.Position
 }
 
 type StringLiteral struct {
-	Token token.Token
-	Value string
+	Token  token.Token
+	Value  string
+	EndPos token.Position
 }
 
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
+	EndPos   token.Position
 }
 
 type IndexExpression struct {
-	Token token.Token
-	Left  Expression
-	Index Expression
+	Token  token.Token
+	Left   Expression
+	Index  Expression
+	EndPos token.Position
+}
+
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+	EndPos    token.Position
+}
+
+type ForStatement struct {
+	Token    token.Token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+	EndPos   token.Position
+}
+
+type BreakStatement struct {
+	Token  token.Token
+	EndPos token.Position
+}
+
+type ContinueStatement struct {
+	Token  token.Token
+	EndPos token.Position
 }
 
 type HashLiteral struct {
-	Token token.Token // '{'
-	Pairs map[Expression]Expression
+	Token  token.Token       // '{'
+	Pairs  []HashLiteralPair //in source order
+	EndPos token.Position
+}
+
+type HashLiteralPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (i *Identifier) String() string {
@@ -128,6 +205,20 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var output bytes.Buffer
 	for _, stmt := range p.Statements {
@@ -136,12 +227,26 @@ func (p *Program) String() string {
 	return output.String()
 }
 
+//endOf falls back to the end of a node's own token when the parser didn't record a wider span
+func endOf(tok token.Token, end token.Position) token.Position {
+	if end.IsValid() {
+		return end
+	}
+	return tok.End
+}
+
 //letstatement functions
 
 func (l *LetStatement) statementNode() {}
 func (l *LetStatement) TokenLiteral() string {
 	return l.Token.Literal
 }
+func (l *LetStatement) Pos() token.Position {
+	return l.Token.Pos
+}
+func (l *LetStatement) End() token.Position {
+	return endOf(l.Token, l.EndPos)
+}
 func (l *LetStatement) String() string {
 	var output bytes.Buffer
 
@@ -160,6 +265,12 @@ func (r *ReturnStatement) statementNode() {}
 func (r *ReturnStatement) TokenLiteral() string {
 	return r.Token.Literal
 }
+func (r *ReturnStatement) Pos() token.Position {
+	return r.Token.Pos
+}
+func (r *ReturnStatement) End() token.Position {
+	return endOf(r.Token, r.EndPos)
+}
 func (r *ReturnStatement) String() string {
 	var output bytes.Buffer
 
@@ -176,6 +287,12 @@ func (es *ExpressionStatement) statementNode() {}
 func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
+func (es *ExpressionStatement) Pos() token.Position {
+	return es.Token.Pos
+}
+func (es *ExpressionStatement) End() token.Position {
+	return endOf(es.Token, es.EndPos)
+}
 func (es *ExpressionStatement) String() string {
 	var output bytes.Buffer
 
@@ -192,6 +309,12 @@ func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
+func (i *Identifier) End() token.Position {
+	return endOf(i.Token, i.EndPos)
+}
 
 //integer functions
 
@@ -199,16 +322,44 @@ func (i *IntegerLiteral) expressionNode() {}
 func (i *IntegerLiteral) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *IntegerLiteral) Pos() token.Position {
+	return i.Token.Pos
+}
+func (i *IntegerLiteral) End() token.Position {
+	return endOf(i.Token, i.EndPos)
+}
 func (i *IntegerLiteral) String() string {
 	return i.Token.Literal
 }
 
+//float functions
+
+func (f *FloatLiteral) expressionNode() {}
+func (f *FloatLiteral) TokenLiteral() string {
+	return f.Token.Literal
+}
+func (f *FloatLiteral) Pos() token.Position {
+	return f.Token.Pos
+}
+func (f *FloatLiteral) End() token.Position {
+	return endOf(f.Token, f.EndPos)
+}
+func (f *FloatLiteral) String() string {
+	return f.Token.Literal
+}
+
 //boolean functions
 
 func (b *Boolean) expressionNode() {}
 func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Pos
+}
+func (b *Boolean) End() token.Position {
+	return endOf(b.Token, b.EndPos)
+}
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -219,6 +370,12 @@ func (pe *PrefixExpression) expressionNode() {}
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
+func (pe *PrefixExpression) Pos() token.Position {
+	return pe.Token.Pos
+}
+func (pe *PrefixExpression) End() token.Position {
+	return endOf(pe.Token, pe.EndPos)
+}
 func (pe *PrefixExpression) String() string {
 	var output bytes.Buffer
 
@@ -234,6 +391,15 @@ func (ie *InfixExpression) expressionNode() {}
 func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *InfixExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *InfixExpression) End() token.Position {
+	return endOf(ie.Token, ie.EndPos)
+}
 func (ie *InfixExpression) String() string {
 	var output bytes.Buffer
 
@@ -252,6 +418,12 @@ func (ie *IfExpression) expressionNode() {}
 func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *IfExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
+func (ie *IfExpression) End() token.Position {
+	return endOf(ie.Token, ie.EndPos)
+}
 func (ie *IfExpression) String() string {
 	var output bytes.Buffer
 
@@ -268,6 +440,12 @@ func (bs *BlockStatement) expressionNode() {}
 func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+func (bs *BlockStatement) End() token.Position {
+	return endOf(bs.Token, bs.EndPos)
+}
 func (bs *BlockStatement) String() string {
 	var output bytes.Buffer
 
@@ -284,12 +462,25 @@ func (fl *FunctionLiteral) expressionNode() {}
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+func (fl *FunctionLiteral) End() token.Position {
+	return endOf(fl.Token, fl.EndPos)
+}
 func (fl *FunctionLiteral) String() string {
 	var output bytes.Buffer
 
 	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i, p := range fl.Parameters {
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			params = append(params, p.String()+" be "+fl.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
 	}
 
 	output.WriteString(fl.TokenLiteral())
@@ -307,6 +498,15 @@ func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
+func (ce *CallExpression) Pos() token.Position {
+	if ce.Function != nil {
+		return ce.Function.Pos()
+	}
+	return ce.Token.Pos
+}
+func (ce *CallExpression) End() token.Position {
+	return endOf(ce.Token, ce.EndPos)
+}
 func (ce *CallExpression) String() string {
 	var output bytes.Buffer
 
@@ -329,6 +529,12 @@ func (s *StringLiteral) expressionNode() {}
 func (s *StringLiteral) TokenLiteral() string {
 	return s.Token.Literal
 }
+func (s *StringLiteral) Pos() token.Position {
+	return s.Token.Pos
+}
+func (s *StringLiteral) End() token.Position {
+	return endOf(s.Token, s.EndPos)
+}
 func (s *StringLiteral) String() string {
 	return s.Token.Literal
 }
@@ -339,6 +545,12 @@ func (al *ArrayLiteral) expressionNode() {}
 func (al *ArrayLiteral) TokenLiteral() string {
 	return al.Token.Literal
 }
+func (al *ArrayLiteral) Pos() token.Position {
+	return al.Token.Pos
+}
+func (al *ArrayLiteral) End() token.Position {
+	return endOf(al.Token, al.EndPos)
+}
 func (al *ArrayLiteral) String() string {
 	var output bytes.Buffer
 
@@ -361,6 +573,15 @@ func (ie *IndexExpression) expressionNode() {}
 func (ie *IndexExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *IndexExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *IndexExpression) End() token.Position {
+	return endOf(ie.Token, ie.EndPos)
+}
 func (ie *IndexExpression) String() string {
 	var output bytes.Buffer
 
@@ -379,14 +600,147 @@ func (hl *HashLiteral) expressionNode() {}
 func (hl *HashLiteral) TokenLiteral() string {
 	return hl.Token.Literal
 }
+func (hl *HashLiteral) Pos() token.Position {
+	return hl.Token.Pos
+}
+func (hl *HashLiteral) End() token.Position {
+	return endOf(hl.Token, hl.EndPos)
+}
 func (hl *HashLiteral) String() string {
 	var output bytes.Buffer
 
 	output.WriteString("{")
-	for key, val := range hl.Pairs {
-		output.WriteString(key.String() + ":" + val.String() + ",")
+	for _, pair := range hl.Pairs {
+		output.WriteString(pair.Key.String() + ":" + pair.Value.String() + ",")
 	}
 	output.WriteString("}")
 
 	return output.String()
 }
+
+//whilestatement functions
+
+func (ws *WhileStatement) statementNode() {}
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+func (ws *WhileStatement) Pos() token.Position {
+	return ws.Token.Pos
+}
+func (ws *WhileStatement) End() token.Position {
+	return endOf(ws.Token, ws.EndPos)
+}
+func (ws *WhileStatement) String() string {
+	var output bytes.Buffer
+
+	output.WriteString("while")
+	output.WriteString(ws.Condition.String() + " ")
+	output.WriteString(ws.Body.String())
+
+	return output.String()
+}
+
+//forstatement functions
+
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+func (fs *ForStatement) Pos() token.Position {
+	return fs.Token.Pos
+}
+func (fs *ForStatement) End() token.Position {
+	return endOf(fs.Token, fs.EndPos)
+}
+func (fs *ForStatement) String() string {
+	var output bytes.Buffer
+
+	output.WriteString("for ")
+	output.WriteString(fs.Variable.String())
+	output.WriteString(" in ")
+	output.WriteString(fs.Iterable.String() + " ")
+	output.WriteString(fs.Body.String())
+
+	return output.String()
+}
+
+//breakstatement functions
+
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BreakStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+func (bs *BreakStatement) End() token.Position {
+	return endOf(bs.Token, bs.EndPos)
+}
+func (bs *BreakStatement) String() string {
+	return bs.Token.Literal + " plz"
+}
+
+//continuestatement functions
+
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+func (cs *ContinueStatement) Pos() token.Position {
+	return cs.Token.Pos
+}
+func (cs *ContinueStatement) End() token.Position {
+	return endOf(cs.Token, cs.EndPos)
+}
+func (cs *ContinueStatement) String() string {
+	return cs.Token.Literal + " plz"
+}
+
+//assignexpression functions
+
+func (te *TryExpression) expressionNode() {}
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+func (te *TryExpression) Pos() token.Position {
+	return te.Token.Pos
+}
+func (te *TryExpression) End() token.Position {
+	return endOf(te.Token, te.EndPos)
+}
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Body.String())
+	out.WriteString(" sorry ")
+	if te.Parameter != nil {
+		out.WriteString("(" + te.Parameter.String() + ") ")
+	}
+	out.WriteString(te.Handler.String())
+
+	return out.String()
+}
+
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AssignExpression) Pos() token.Position {
+	if ae.Target != nil {
+		return ae.Target.Pos()
+	}
+	return ae.Token.Pos
+}
+func (ae *AssignExpression) End() token.Position {
+	return endOf(ae.Token, ae.EndPos)
+}
+func (ae *AssignExpression) String() string {
+	var output bytes.Buffer
+
+	output.WriteString(ae.Target.String())
+	output.WriteString(" " + ae.Operator + " ")
+	output.WriteString(ae.Value.String())
+
+	return output.String()
+}
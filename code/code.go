@@ -0,0 +1,230 @@
+//bytecode instruction set shared by the compiler and vm packages
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+	OpDup
+	OpNull
+	OpNil //no value, what a statement such as let leaves behind
+	OpTrue
+	OpFalse
+
+	OpInfix  //operand: index into Operators
+	OpPrefix //operand: index into Operators
+	OpCompound
+
+	OpJump
+	OpJumpNotTruthy
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetCell //locals captured by closures live in cells
+	OpSetCell
+	OpLoadCell
+	OpBoxLocal
+	OpDefault
+	OpGetFree
+	OpSetFree
+	OpLoadFree
+	OpRequireDefined
+
+	OpArray
+	OpHash
+	OpIndex
+	OpSetIndex
+
+	OpCall
+	OpReturnValue
+	OpClosure
+
+	OpIter
+	OpIterNext
+
+	OpError
+	OpTry
+	OpEndTry
+)
+
+//scopes named by the first operand of OpRequireDefined
+const (
+	ScopeGlobal = iota
+	ScopeLocal
+	ScopeCell
+	ScopeFree
+)
+
+//Operators lists the infix and prefix operators, indexed by the operand of OpInfix, OpPrefix,
+//OpCompound and OpSetIndex
+var Operators = []string{"=", "+", "-", "*", "/", "==", "!=", "<", ">", "!"}
+
+//OperatorIndex returns the operand encoding an operator, or -1 if it isn't known
+func OperatorIndex(op string) int {
+	for i, candidate := range Operators {
+		if candidate == op {
+			return i
+		}
+	}
+	return -1
+}
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+	OpDup:      {"OpDup", []int{}},
+	OpNull:     {"OpNull", []int{}},
+	OpNil:      {"OpNil", []int{}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+
+	OpInfix:    {"OpInfix", []int{1}},
+	OpPrefix:   {"OpPrefix", []int{1}},
+	OpCompound: {"OpCompound", []int{1}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+
+	OpGetGlobal:      {"OpGetGlobal", []int{2}},
+	OpSetGlobal:      {"OpSetGlobal", []int{2}},
+	OpGetLocal:       {"OpGetLocal", []int{1}},
+	OpSetLocal:       {"OpSetLocal", []int{1}},
+	OpGetCell:        {"OpGetCell", []int{1}},
+	OpSetCell:        {"OpSetCell", []int{1}},
+	OpLoadCell:       {"OpLoadCell", []int{1}},
+	OpBoxLocal:       {"OpBoxLocal", []int{1}},
+	OpDefault:        {"OpDefault", []int{1, 2}}, //parameter slot, jump target when it was passed
+	OpGetFree:        {"OpGetFree", []int{1}},
+	OpSetFree:        {"OpSetFree", []int{1}},
+	OpLoadFree:       {"OpLoadFree", []int{1}},
+	OpRequireDefined: {"OpRequireDefined", []int{1, 2}}, //scope, index
+
+	OpArray:    {"OpArray", []int{2}},
+	OpHash:     {"OpHash", []int{2}},
+	OpIndex:    {"OpIndex", []int{}},
+	OpSetIndex: {"OpSetIndex", []int{1}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpClosure:     {"OpClosure", []int{2, 1}}, //constant index, number of free variables
+
+	OpIter:     {"OpIter", []int{}},
+	OpIterNext: {"OpIterNext", []int{2}}, //jump target once the iterator is exhausted
+
+	OpError:  {"OpError", []int{2}},
+	OpTry:    {"OpTry", []int{2}}, //where the handler starts
+	OpEndTry: {"OpEndTry", []int{}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+//Make encodes an instruction, with multi-byte operands in big endian order
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+//ReadOperands decodes the operands following an opcode, returning them and the bytes read
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
+//String disassembles the instructions, one per line prefixed with its offset
+func (ins Instructions) String() string {
+	var output bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&output, "ERROR: %s\n", err)
+			return output.String()
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&output, "%04d %s\n", i, formatInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return output.String()
+}
+
+func formatInstruction(def *Definition, operands []int) string {
+	switch len(operands) {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+	return fmt.Sprintf("ERROR: unhandled operand count for %s", def.Name)
+}
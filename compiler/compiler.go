@@ -0,0 +1,777 @@
+//compiles the syntax tree to bytecode for the vm package
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/MYKatz/PLZ/ast"
+	"github.com/MYKatz/PLZ/code"
+	"github.com/MYKatz/PLZ/evaluator"
+	"github.com/MYKatz/PLZ/object"
+	"github.com/MYKatz/PLZ/token"
+)
+
+type Bytecode struct {
+	Main        *object.CompiledFunction
+	Constants   []object.Object
+	GlobalNames []string //names of the global slots, for error messages
+}
+
+type loop struct {
+	start      int   //where continue jumps to
+	breakJumps []int //OpJump instructions to patch with the loop's exit
+	tries      int   //try expressions already open when the loop started
+}
+
+type compilationScope struct {
+	instructions code.Instructions
+	positions    []object.InstructionPos
+	loops        []*loop
+	tries        int //try expressions currently open
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	builtins    map[string]int //constant index of each builtin used so far
+	table       map[string]*object.BuiltIn
+
+	scopes     []*compilationScope
+	scopeIndex int
+	pos        token.Position //position of the innermost node being compiled
+	err        error          //the first operand too large for its instruction, returned by Compile
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+//NewWithState creates a compiler that keeps the globals and constants of earlier compilations
+func NewWithState(symbols *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: symbols,
+		builtins:    make(map[string]int),
+		scopes:      []*compilationScope{{}},
+	}
+}
+
+//SetBuiltins makes table the builtin functions programs can call, instead of the evaluator's defaults
+func (c *Compiler) SetBuiltins(table map[string]*object.BuiltIn) {
+	c.table = table
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	if node == nil {
+		c.emit(code.OpNil)
+		return nil
+	}
+
+	outerPos := c.pos
+	if pos := node.Pos(); pos.IsValid() {
+		c.pos = pos
+	}
+	defer func() { c.pos = outerPos }()
+
+	switch node := node.(type) {
+	case *ast.Program:
+		if err := c.compileStatements(node.Statements); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.BlockStatement:
+		return c.compileStatements(node.Statements)
+	case *ast.ExpressionStatement:
+		return c.Compile(node.Expression)
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		return c.emitOperator(code.OpPrefix, node.Operator)
+	case *ast.InfixExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		return c.emitOperator(code.OpInfix, node.Operator)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.TryExpression:
+		return c.compileTryExpression(node)
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+	case *ast.Identifier:
+		c.compileIdentifier(node.Value)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, arg := range node.Arguments {
+			if err := c.Compile(arg); err != nil {
+				return err
+			}
+		}
+		if len(node.Arguments) > 255 {
+			return fmt.Errorf("%s: too many arguments in call", node.Pos())
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+	case *ast.BreakStatement:
+		current := c.currentLoop()
+		if current == nil {
+			return fmt.Errorf("%s: 'break' outside of a loop", node.Pos())
+		}
+		c.closeTries(current)
+		current.breakJumps = append(current.breakJumps, c.emit(code.OpJump, 0))
+	case *ast.ContinueStatement:
+		current := c.currentLoop()
+		if current == nil {
+			return fmt.Errorf("%s: 'continue' outside of a loop", node.Pos())
+		}
+		c.closeTries(current)
+		c.emit(code.OpJump, current.start)
+	default:
+		return fmt.Errorf("%s: cannot compile %T", node.Pos(), node)
+	}
+
+	return c.err
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Main: &object.CompiledFunction{
+			Instructions: c.currentInstructions(),
+			Positions:    c.scopes[c.scopeIndex].positions,
+		},
+		Constants:   c.constants,
+		GlobalNames: c.symbolTable.global().Names(),
+	}
+}
+
+//compileStatements leaves the value of the last statement on the stack, like evalBlockStatement.
+//Every statement pushes exactly one value, so all but the last are popped
+func (c *Compiler) compileStatements(statements []ast.Statement) error {
+	if len(statements) == 0 {
+		c.emit(code.OpNil)
+		return nil
+	}
+
+	for i, s := range statements {
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+		if i < len(statements)-1 {
+			c.emit(code.OpPop)
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 0)
+	if err := c.Compile(node.Consequence); err != nil {
+		return err
+	}
+	jump := c.emit(code.OpJump, 0)
+
+	c.changeOperand(jumpNotTruthy, len(c.currentInstructions()))
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.Compile(node.Alternative); err != nil {
+		return err
+	}
+	c.changeOperand(jump, len(c.currentInstructions()))
+
+	return nil
+}
+
+//compileTryExpression lays out
+//
+//	OpTry handler; body; OpEndTry; OpJump end
+//	handler: set parameter to the caught error (pushed by the vm); handler block
+//	end:
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	scope := c.scopes[c.scopeIndex]
+
+	try := c.emit(code.OpTry, 0)
+	scope.tries++
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	scope.tries--
+	c.emit(code.OpEndTry)
+	jump := c.emit(code.OpJump, 0)
+
+	c.changeOperand(try, len(c.currentInstructions()))
+	if node.Parameter != nil {
+		c.setSymbol(c.symbolTable.Define(node.Parameter.Value))
+	} else {
+		c.emit(code.OpPop)
+	}
+	if err := c.Compile(node.Handler); err != nil {
+		return err
+	}
+	c.changeOperand(jump, len(c.currentInstructions()))
+
+	return nil
+}
+
+//closeTries leaves the try expressions a break or continue jumps out of
+func (c *Compiler) closeTries(l *loop) {
+	for i := l.tries; i < c.scopes[c.scopeIndex].tries; i++ {
+		c.emit(code.OpEndTry)
+	}
+}
+
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+
+	name := node.Name.Value
+	if _, ok := c.lookupBuiltin(name); ok {
+		c.emit(code.OpPop)
+		c.emitError("Invalid let statement: cannot override builtin function %s", name)
+		c.emit(code.OpNil)
+		return nil
+	}
+
+	c.setSymbol(c.symbolTable.Define(name))
+	c.emit(code.OpNil)
+	return nil
+}
+
+func (c *Compiler) compileIdentifier(name string) {
+	if builtin, ok := c.lookupBuiltin(name); ok {
+		c.emit(code.OpConstant, c.builtinConstant(name, builtin))
+		return
+	}
+
+	symbol := c.resolve(name)
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, symbol.Index)
+	case LocalScope:
+		if symbol.Boxed {
+			c.emit(code.OpGetCell, symbol.Index)
+		} else {
+			c.emit(code.OpGetLocal, symbol.Index)
+		}
+	case FreeScope:
+		c.emit(code.OpGetFree, symbol.Index)
+	}
+}
+
+//resolve looks name up, treating unknown names as globals that may be defined before the code
+//runs; reading one that never was is a runtime error, as in the evaluator
+func (c *Compiler) resolve(name string) Symbol {
+	if symbol, ok := c.symbolTable.Resolve(name); ok {
+		return symbol
+	}
+	return c.symbolTable.global().Define(name)
+}
+
+//setSymbol pops the top of the stack into the variable
+func (c *Compiler) setSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, symbol.Index)
+	case LocalScope:
+		if symbol.Boxed {
+			c.emit(code.OpSetCell, symbol.Index)
+		} else {
+			c.emit(code.OpSetLocal, symbol.Index)
+		}
+	case FreeScope:
+		c.emit(code.OpSetFree, symbol.Index)
+	}
+}
+
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	operator := node.Operator
+	if operator == "be" {
+		operator = "="
+	} else if operator != "=" {
+		operator = operator[:len(operator)-1] //"+=" applies "+"
+	}
+	opIndex := code.OperatorIndex(operator)
+	if opIndex < 0 {
+		return fmt.Errorf("%s: unknown assignment operator %s", node.Pos(), node.Operator)
+	}
+
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol := c.resolve(target.Value)
+		switch {
+		case symbol.Scope == GlobalScope:
+			c.emit(code.OpRequireDefined, code.ScopeGlobal, symbol.Index)
+		case symbol.Scope == FreeScope:
+			c.emit(code.OpRequireDefined, code.ScopeFree, symbol.Index)
+		case symbol.Boxed:
+			c.emit(code.OpRequireDefined, code.ScopeCell, symbol.Index)
+		default:
+			c.emit(code.OpRequireDefined, code.ScopeLocal, symbol.Index)
+		}
+		c.compileIdentifier(target.Value)
+		c.emit(code.OpCompound, opIndex)
+		c.emit(code.OpDup)
+		c.setSymbol(symbol)
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpSetIndex, opIndex)
+	default:
+		c.emitError("cannot assign to %s", node.Target.String())
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	start := len(c.currentInstructions())
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(code.OpJumpNotTruthy, 0)
+
+	current := c.enterLoop(start)
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	c.emit(code.OpJump, start)
+	c.leaveLoop()
+
+	end := len(c.currentInstructions())
+	c.changeOperand(exit, end)
+	for _, jump := range current.breakJumps {
+		c.changeOperand(jump, end)
+	}
+
+	c.emit(code.OpNil)
+	return nil
+}
+
+//compileForStatement keeps an iterator on the stack while the loop runs:
+//
+//	OpIter
+//	start: OpIterNext done; set variable; body; OpPop; OpJump start
+//	break: OpPop
+//	done:  OpNil
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+
+	start := c.emit(code.OpIterNext, 0)
+	c.setSymbol(c.symbolTable.Define(node.Variable.Value))
+
+	current := c.enterLoop(start)
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	c.emit(code.OpJump, start)
+	c.leaveLoop()
+
+	for _, jump := range current.breakJumps {
+		c.changeOperand(jump, len(c.currentInstructions()))
+	}
+	c.emit(code.OpPop)
+
+	c.changeOperand(start, len(c.currentInstructions()))
+	c.emit(code.OpNil)
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	if len(node.Parameters) > 255 {
+		return fmt.Errorf("%s: too many parameters", node.Pos())
+	}
+
+	c.enterScope()
+
+	for _, param := range node.Parameters {
+		c.symbolTable.Define(param.Value)
+	}
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+	}
+
+	//locals captured by inner functions get their cells up front, so a closure created before
+	//the variable's let still sees it
+	captured := capturedNames(node)
+	for _, name := range declaredNames(node.Body) {
+		if captured[name] {
+			c.symbolTable.Define(name)
+		}
+	}
+	for name := range captured {
+		c.symbolTable.Box(name)
+	}
+	for i, name := range c.symbolTable.names {
+		if c.symbolTable.store[name].Boxed {
+			c.emit(code.OpBoxLocal, i)
+		}
+	}
+
+	//parameters that were left out run their default in order, after boxing so a default
+	//may be captured like any other value
+	for i, value := range node.Defaults {
+		if value == nil {
+			continue
+		}
+		pos := c.emit(code.OpDefault, i, 9999)
+		if err := c.Compile(value); err != nil {
+			return err
+		}
+		symbol, _ := c.symbolTable.Resolve(node.Parameters[i].Value)
+		c.setSymbol(symbol)
+		c.changeOperand(pos, i, len(c.currentInstructions()))
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	required := 0
+	for required < len(node.Defaults) && node.Defaults[required] == nil {
+		required++
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	fn := &object.CompiledFunction{
+		Name:          node.Name,
+		NumLocals:     c.symbolTable.numDefinitions,
+		NumParameters: len(node.Parameters),
+		NumRequired:   required,
+		Variadic:      node.Rest != nil,
+		LocalNames:    c.symbolTable.Names(),
+		FreeNames:     c.symbolTable.freeNames(),
+	}
+	fn.Instructions, fn.Positions = c.leaveScope()
+
+	if fn.NumLocals > 255 || len(freeSymbols) > 255 {
+		return fmt.Errorf("%s: too many variables in function", node.Pos())
+	}
+
+	for _, symbol := range freeSymbols {
+		switch {
+		case symbol.Scope == FreeScope:
+			c.emit(code.OpLoadFree, symbol.Index)
+		case symbol.Scope == LocalScope && symbol.Boxed:
+			c.emit(code.OpLoadCell, symbol.Index)
+		default:
+			return fmt.Errorf("%s: variable %s is captured but has no cell", node.Pos(), symbol.Name)
+		}
+	}
+
+	c.emit(code.OpClosure, c.addConstant(fn), len(freeSymbols))
+	return nil
+}
+
+func (c *Compiler) emitOperator(op code.Opcode, operator string) error {
+	index := code.OperatorIndex(operator)
+	if index < 0 {
+		c.emitError("unknown operator: %s", operator)
+		return nil
+	}
+	c.emit(op, index)
+	return nil
+}
+
+//emitError compiles an instruction that fails at runtime with the given message
+func (c *Compiler) emitError(format string, a ...interface{}) {
+	message := &object.String{Value: fmt.Sprintf(format, a...)}
+	c.emit(code.OpError, c.addConstant(message))
+}
+
+func (c *Compiler) lookupBuiltin(name string) (*object.BuiltIn, bool) {
+	if c.table == nil {
+		return evaluator.LookupBuiltin(name)
+	}
+	builtin, ok := c.table[name]
+	return builtin, ok
+}
+
+func (c *Compiler) builtinConstant(name string, builtin *object.BuiltIn) int {
+	if index, ok := c.builtins[name]; ok {
+		return index
+	}
+	index := c.addConstant(builtin)
+	c.builtins[name] = index
+	return index
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+//emit appends an instruction, returning its offset
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	scope := c.scopes[c.scopeIndex]
+	offset := len(scope.instructions)
+
+	if n := len(scope.positions); n == 0 || scope.positions[n-1].Pos != c.pos {
+		scope.positions = append(scope.positions, object.InstructionPos{Offset: offset, Pos: c.pos})
+	}
+	c.checkOperands(op, operands)
+	scope.instructions = append(scope.instructions, code.Make(op, operands...)...)
+
+	return offset
+}
+
+func (c *Compiler) changeOperand(offset int, operands ...int) {
+	ins := c.currentInstructions()
+	op := code.Opcode(ins[offset])
+	c.checkOperands(op, operands)
+	copy(ins[offset:], code.Make(op, operands...))
+}
+
+//checkOperands records an error if an operand doesn't fit in the bytes op has for it, as
+//happens when a program has more than 65536 constants or over 64KB of instructions in one
+//function, rather than letting code.Make truncate it
+func (c *Compiler) checkOperands(op code.Opcode, operands []int) {
+	if c.err != nil {
+		return
+	}
+	def, err := code.Lookup(byte(op))
+	if err != nil {
+		c.err = err
+		return
+	}
+
+	for i, operand := range operands {
+		max := 1<<(8*uint(def.OperandWidths[i])) - 1
+		if operand < 0 || operand > max {
+			c.err = fmt.Errorf("%s: program too large: %s operand %d is over the limit of %d", c.pos, def.Name, operand, max)
+			return
+		}
+	}
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, &compilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() (code.Instructions, []object.InstructionPos) {
+	scope := c.scopes[c.scopeIndex]
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+
+	return scope.instructions, scope.positions
+}
+
+func (c *Compiler) enterLoop(start int) *loop {
+	scope := c.scopes[c.scopeIndex]
+	l := &loop{start: start, tries: scope.tries}
+	scope.loops = append(scope.loops, l)
+	return l
+}
+
+func (c *Compiler) leaveLoop() {
+	scope := c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+}
+
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+	return loops[len(loops)-1]
+}
+
+//declaredNames lists the variables a function body declares itself with let or for,
+//in the order they appear
+func declaredNames(body *ast.BlockStatement) []string {
+	names := []string{}
+	walk(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement:
+			names = append(names, node.Name.Value)
+		case *ast.ForStatement:
+			names = append(names, node.Variable.Value)
+		case *ast.TryExpression:
+			if node.Parameter != nil {
+				names = append(names, node.Parameter.Value)
+			}
+		}
+		return true
+	})
+	return names
+}
+
+//capturedNames over-approximates the variables that functions nested in fn's defaults and body
+//may refer to
+func capturedNames(fn *ast.FunctionLiteral) map[string]bool {
+	names := map[string]bool{}
+	walk(fn, func(node ast.Node) bool {
+		if node == fn {
+			return true
+		}
+		if fn, ok := node.(*ast.FunctionLiteral); ok {
+			walk(fn, func(inner ast.Node) bool {
+				if ident, ok := inner.(*ast.Identifier); ok {
+					names[ident.Value] = true
+				}
+				return true
+			})
+			return false
+		}
+		return true
+	})
+	return names
+}
+
+//walk calls visit on node and, while visit returns true, on its children
+func walk(node ast.Node, visit func(ast.Node) bool) {
+	if node == nil || !visit(node) {
+		return
+	}
+
+	children := []ast.Node{}
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			children = append(children, s)
+		}
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			children = append(children, s)
+		}
+	case *ast.ExpressionStatement:
+		children = append(children, node.Expression)
+	case *ast.LetStatement:
+		children = append(children, node.Name, node.Value)
+	case *ast.ReturnStatement:
+		children = append(children, node.Value)
+	case *ast.PrefixExpression:
+		children = append(children, node.Right)
+	case *ast.InfixExpression:
+		children = append(children, node.Left, node.Right)
+	case *ast.IfExpression:
+		children = append(children, node.Condition, node.Consequence)
+		if node.Alternative != nil {
+			children = append(children, node.Alternative)
+		}
+	case *ast.AssignExpression:
+		children = append(children, node.Target, node.Value)
+	case *ast.FunctionLiteral:
+		for i, param := range node.Parameters {
+			children = append(children, param)
+			if i < len(node.Defaults) && node.Defaults[i] != nil {
+				children = append(children, node.Defaults[i])
+			}
+		}
+		if node.Rest != nil {
+			children = append(children, node.Rest)
+		}
+		children = append(children, node.Body)
+	case *ast.CallExpression:
+		children = append(children, node.Function)
+		for _, arg := range node.Arguments {
+			children = append(children, arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			children = append(children, el)
+		}
+	case *ast.IndexExpression:
+		children = append(children, node.Left, node.Index)
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			children = append(children, pair.Key, pair.Value)
+		}
+	case *ast.WhileStatement:
+		children = append(children, node.Condition, node.Body)
+	case *ast.ForStatement:
+		children = append(children, node.Variable, node.Iterable, node.Body)
+	case *ast.TryExpression:
+		children = append(children, node.Body)
+		if node.Parameter != nil {
+			children = append(children, node.Parameter)
+		}
+		children = append(children, node.Handler)
+	}
+
+	for _, child := range children {
+		walk(child, visit)
+	}
+}
@@ -0,0 +1,193 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/MYKatz/PLZ/code"
+	"github.com/MYKatz/PLZ/lexer"
+	"github.com/MYKatz/PLZ/object"
+	"github.com/MYKatz/PLZ/parser"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []code.Instructions
+	}{
+		{"1 + 2", []code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpInfix, code.OperatorIndex("+")),
+			code.Make(code.OpReturnValue),
+		}},
+		{"let x be 1 plz x", []code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpNil),
+			code.Make(code.OpPop),
+			code.Make(code.OpGetGlobal, 0),
+			code.Make(code.OpReturnValue),
+		}},
+		{"if (True) please 1 thanks", []code.Instructions{
+			code.Make(code.OpTrue),
+			code.Make(code.OpJumpNotTruthy, 10),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpJump, 11),
+			code.Make(code.OpNull),
+			code.Make(code.OpReturnValue),
+		}},
+		{"let x be 1 plz x += 2", []code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpNil),
+			code.Make(code.OpPop),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpRequireDefined, code.ScopeGlobal, 0),
+			code.Make(code.OpGetGlobal, 0),
+			code.Make(code.OpCompound, code.OperatorIndex("+")),
+			code.Make(code.OpDup),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpReturnValue),
+		}},
+		{"while (True) please break thanks", []code.Instructions{
+			code.Make(code.OpTrue),
+			code.Make(code.OpJumpNotTruthy, 11),
+			code.Make(code.OpJump, 11),
+			code.Make(code.OpPop),
+			code.Make(code.OpJump, 0),
+			code.Make(code.OpNil),
+			code.Make(code.OpReturnValue),
+		}},
+	}
+
+	for _, tt := range tests {
+		bytecode := compile(t, tt.input)
+
+		expected := code.Instructions{}
+		for _, ins := range tt.expected {
+			expected = append(expected, ins...)
+		}
+
+		if bytecode.Main.Instructions.String() != expected.String() {
+			t.Errorf("Wrong instructions for %q.\nExpected:\n%s\nReceived:\n%s", tt.input, expected, bytecode.Main.Instructions)
+		}
+	}
+}
+
+func TestOperandLimits(t *testing.T) {
+	numbers := func(from int, to int) string {
+		var out strings.Builder
+		for i := from; i < to; i++ {
+			fmt.Fprintf(&out, "%d, ", i)
+		}
+		return out.String()
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[" + numbers(0, 40000) + "0] plz [" + numbers(40000, 80000) + "0]", "1:447645: program too large: OpConstant operand 65536 is over the limit of 65535"},
+		{"let x be 0 plz if (True) please " + strings.Repeat("x += 1 plz ", 20000) + "thanks", "1:16: program too large: OpJumpNotTruthy operand 340014 is over the limit of 65535"},
+		{"[" + strings.Repeat("True, ", 70000) + "True]", "1:1: program too large: OpArray operand 70001 is over the limit of 65535"},
+	}
+
+	for _, tt := range tests {
+		p := parser.NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+
+		err := New().Compile(program)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("Incorrect error. Expected %q, received %v", tt.expected, err)
+		}
+	}
+}
+
+func TestCapturedLocalsAreBoxed(t *testing.T) {
+	input := `function(a) please
+		let b be 1 plz
+		let c be 2 plz
+		function() please a + c thanks
+	thanks`
+
+	bytecode := compile(t, input)
+
+	var fn *object.CompiledFunction
+	for _, constant := range bytecode.Constants {
+		if compiled, ok := constant.(*object.CompiledFunction); ok && compiled.NumParameters == 1 {
+			fn = compiled
+		}
+	}
+	if fn == nil {
+		t.Fatalf("outer function not found in constants")
+	}
+
+	if fn.NumLocals != 3 {
+		t.Errorf("Wrong number of locals. Expected 3, received %d", fn.NumLocals)
+	}
+
+	expected := code.Instructions{}
+	expected = append(expected, code.Make(code.OpBoxLocal, 0)...) //a
+	expected = append(expected, code.Make(code.OpBoxLocal, 1)...) //c, hoisted because it is captured
+	if fn.Instructions.String()[:len(expected.String())] != expected.String() {
+		t.Errorf("Captured locals not boxed on entry. Received:\n%s", fn.Instructions)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	outer := NewEnclosedSymbolTable(global)
+	outer.Define("b")
+
+	inner := NewEnclosedSymbolTable(outer)
+	inner.Define("c")
+
+	tests := []struct {
+		name     string
+		expected Symbol
+	}{
+		{"a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"b", Symbol{Name: "b", Scope: FreeScope, Index: 0}},
+		{"c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+	}
+
+	for _, tt := range tests {
+		symbol, ok := inner.Resolve(tt.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+			continue
+		}
+		if symbol != tt.expected {
+			t.Errorf("Wrong symbol for %s. Expected %+v, received %+v", tt.name, tt.expected, symbol)
+		}
+	}
+
+	if len(inner.FreeSymbols) != 1 || inner.FreeSymbols[0].Name != "b" || inner.FreeSymbols[0].Scope != LocalScope {
+		t.Errorf("Wrong free symbols, received %+v", inner.FreeSymbols)
+	}
+
+	if _, ok := inner.Resolve("d"); ok {
+		t.Errorf("undefined name d was resolved")
+	}
+}
+
+func compile(t *testing.T, input string) *Bytecode {
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	c := New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compiler error for %q: %s", input, err)
+	}
+	return c.Bytecode()
+}
@@ -1,16 +1,39 @@
 package evaluator
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/MYKatz/PLZ/object"
 )
 
+//defaultBuiltins are used by environments that weren't given a table of their own
+var defaultBuiltins = NewBuiltins(os.Stdout, os.Stdin, 0)
+
+//NewBuiltins creates the builtin functions for a program whose print writes to stdout and whose
+//input reads lines from stdin. print cuts the text of a value short after maxOutput bytes, or
+//never if it is 0
+func NewBuiltins(stdout io.Writer, stdin io.Reader, maxOutput int) map[string]*object.BuiltIn {
+	table := make(map[string]*object.BuiltIn, len(builtins)+2)
+	for name, builtin := range builtins {
+		table[name] = builtin
+	}
+	table["print"] = newPrint(stdout, maxOutput)
+	table["input"] = newInput(stdin, stdout)
+	return table
+}
+
+//builtins that don't touch the outside world, shared by every table
 var builtins = map[string]*object.BuiltIn{
 	"len": &object.BuiltIn{
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("Incorrect number of arguments. Expected 1, received %d", len(args))
+			if err := object.CheckArgCount(args, 1, 1); err != nil {
+				return err
 			}
 
 			switch arg := args[0].(type) {
@@ -25,12 +48,12 @@ var builtins = map[string]*object.BuiltIn{
 	},
 	"append": &object.BuiltIn{
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("Incorrect number of arguments. Expected 2, received %d", len(args))
+			if err := object.CheckArgCount(args, 2, 2); err != nil {
+				return err
 			}
 			switch arg := args[0].(type) {
 			case *object.Array:
-				return &object.Array{Elements: append(arg.Elements, args[1])}
+				return arg.Append(args[1])
 			default:
 				return newError("Invalid first argument to append, received %s", args[0].Type())
 			}
@@ -38,11 +61,14 @@ var builtins = map[string]*object.BuiltIn{
 	},
 	"peek": &object.BuiltIn{
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("Incorrect number of arguments. Expected 1, received %d", len(args))
+			if err := object.CheckArgCount(args, 1, 1); err != nil {
+				return err
 			}
 			switch arg := args[0].(type) {
 			case *object.Array:
+				if len(arg.Elements) == 0 {
+					return NULL
+				}
 				return arg.Elements[len(arg.Elements)-1]
 			default:
 				return newError("Invalid argument to peek, received %s", args[0].Type())
@@ -51,11 +77,14 @@ var builtins = map[string]*object.BuiltIn{
 	},
 	"first": &object.BuiltIn{
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("Incorrect number of arguments. Expected 1, received %d", len(args))
+			if err := object.CheckArgCount(args, 1, 1); err != nil {
+				return err
 			}
 			switch arg := args[0].(type) {
 			case *object.Array:
+				if len(arg.Elements) == 0 {
+					return NULL
+				}
 				return arg.Elements[0]
 			default:
 				return newError("Invalid argument to peek, received %s", args[0].Type())
@@ -64,55 +93,219 @@ var builtins = map[string]*object.BuiltIn{
 	},
 	"rest": &object.BuiltIn{
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("Incorrect number of arguments. Expected 1, received %d", len(args))
+			if err := object.CheckArgCount(args, 1, 1); err != nil {
+				return err
 			}
 			switch arg := args[0].(type) {
 			case *object.Array:
-				return &object.Array{Elements: arg.Elements[1:]}
+				if len(arg.Elements) == 0 {
+					return &object.Array{Elements: []object.Object{}}
+				}
+				return &object.Array{Elements: copyElements(arg.Elements[1:])}
 			default:
 				return newError("Invalid argument to peek, received %s", args[0].Type())
 			}
 		},
 	},
-	"print": &object.BuiltIn{
-		Fn: func(args ...object.Object) object.Object {
-			for _, obj := range args {
-				fmt.Println(obj.Inspect())
-			}
-
-			return NULL
-		},
-	},
 	"assign": &object.BuiltIn{
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 3 {
-				return newError("Incorrect number of arguments. Expected 3, received %d", len(args))
+			if err := object.CheckArgCount(args, 3, 3); err != nil {
+				return err
 			}
 
 			switch arg := args[0].(type) {
 			case *object.Array:
-				i, ok := args[1].(*object.Integer)
-				if !ok {
-					return newError("Invalid second argument. Expected integer, received %T", args[1])
+				if _, ok := args[1].(*object.Integer); !ok {
+					return newError("Invalid second argument. Expected integer, received %s", args[1].Type())
 				}
-
-				elems := arg.Elements
-				ind := i.Value
-				elems[ind] = args[2]
-				return &object.Array{Elements: elems}
+				if err := evalIndexAssignment("=", arg, args[1], args[2]); isError(err) {
+					return err
+				}
+				return arg
 			case *object.HashMap:
 				key, ok := args[1].(object.Hashable)
 				if !ok {
 					return newError("not hashable: %s", args[1].Type())
 				}
-				pairs := arg.Pairs
-				hashkey := key.HashKey()
-				pairs[hashkey] = object.HashPair{Key: args[1], Value: args[2]}
-				return &object.HashMap{Pairs: pairs}
+				arg.Set(key, args[2])
+				return arg
 			default:
 				return newError("Invalid argument to peek, received %s", args[0].Type())
 			}
 		},
 	},
+	"int": &object.BuiltIn{
+		Fn: func(args ...object.Object) object.Object {
+			if err := object.CheckArgCount(args, 1, 1); err != nil {
+				return err
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+					return newError("Could not convert %s to int", arg.Inspect())
+				}
+				return &object.Integer{Value: int64(arg.Value)}
+			case *object.String:
+				value, err := strconv.ParseInt(arg.Value, 10, 64)
+				if err != nil {
+					return newError("Could not convert %q to int", arg.Value)
+				}
+				return &object.Integer{Value: value}
+			default:
+				return newError("Invalid argument to int, received %s", args[0].Type())
+			}
+		},
+	},
+	"float": &object.BuiltIn{
+		Fn: func(args ...object.Object) object.Object {
+			if err := object.CheckArgCount(args, 1, 1); err != nil {
+				return err
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return &object.Float{Value: float64(arg.Value)}
+			case *object.Float:
+				return arg
+			case *object.String:
+				value, err := strconv.ParseFloat(arg.Value, 64)
+				if err != nil {
+					return newError("Could not convert %q to float", arg.Value)
+				}
+				return &object.Float{Value: value}
+			default:
+				return newError("Invalid argument to float, received %s", args[0].Type())
+			}
+		},
+	},
+	"round": &object.BuiltIn{
+		Fn: func(args ...object.Object) object.Object {
+			if err := object.CheckArgCount(args, 1, 2); err != nil {
+				return err
+			}
+			if !isNumber(args[0]) {
+				return newError("Invalid first argument to round, received %s", args[0].Type())
+			}
+			value := toFloat(args[0])
+
+			if len(args) == 1 { //round to the nearest integer, halves away from zero
+				return &object.Integer{Value: int64(math.Round(value))}
+			}
+
+			digits, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("Invalid second argument to round. Expected integer, received %s", args[1].Type())
+			}
+			scale := math.Pow(10, float64(digits.Value))
+			return &object.Float{Value: math.Round(value*scale) / scale}
+		},
+	},
+	"complain": complain,
+	"raise":    complain,
+}
+
+func newPrint(stdout io.Writer, maxOutput int) *object.BuiltIn {
+	return &object.BuiltIn{
+		Fn: func(args ...object.Object) object.Object {
+			for _, obj := range args {
+				text, truncated := object.InspectLimit(obj, maxOutput)
+				if truncated {
+					text += "..."
+				}
+				fmt.Fprintln(stdout, text)
+			}
+
+			return NULL
+		},
+	}
+}
+
+//newInput creates input([prompt]), which writes the prompt and reads a line, returning Null
+//once there is nothing left to read
+func newInput(stdin io.Reader, stdout io.Writer) *object.BuiltIn {
+	var reader *bufio.Reader
+	if stdin != nil {
+		reader = bufio.NewReader(stdin) //returns stdin itself if it is already buffered
+	}
+
+	return &object.BuiltIn{
+		Fn: func(args ...object.Object) object.Object {
+			if err := object.CheckArgCount(args, 0, 1); err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				prompt, ok := openHashObj(args[0]).(*object.String)
+				if !ok {
+					return newError("Invalid argument to input, received %s", args[0].Type())
+				}
+				io.WriteString(stdout, prompt.Value)
+			}
+
+			if reader == nil {
+				return NULL
+			}
+			line, err := reader.ReadString('\n')
+			if err != nil && line == "" {
+				return NULL
+			}
+			return &object.String{Value: strings.TrimRight(line, "\r\n")}
+		},
+	}
+}
+
+//complain raises an error: complain(message), complain(message, type) or complain(caught) to
+//raise an error caught by a try expression again
+var complain = &object.BuiltIn{
+	Fn: func(args ...object.Object) object.Object {
+		if err := object.CheckArgCount(args, 1, 2); err != nil {
+			return err
+		}
+
+		raised := &object.Error{Kind: object.PLAIN_ERROR}
+		switch arg := openHashObj(args[0]).(type) {
+		case *object.String:
+			raised.Message = arg.Value
+		case *object.HashMap: //an error value from a sorry block
+			message, ok := hashField(arg, "message").(*object.String)
+			if !ok {
+				return newError("Invalid argument to complain, hash has no message")
+			}
+			raised.Message = message.Value
+			if kind, ok := hashField(arg, "type").(*object.String); ok {
+				raised.Kind = kind.Value
+			}
+		default:
+			return newError("Invalid argument to complain, received %s", args[0].Type())
+		}
+
+		if len(args) == 2 {
+			kind, ok := openHashObj(args[1]).(*object.String)
+			if !ok {
+				return newError("Invalid second argument to complain. Expected string, received %s", args[1].Type())
+			}
+			raised.Kind = kind.Value
+		}
+		if raised.Kind == object.LIMIT_ERROR {
+			raised.Kind = object.PLAIN_ERROR //only the interpreter may stop a run for good
+		}
+
+		return raised
+	},
+}
+
+//copyElements copies elements into a slice of their own, so that changing one array through
+//assign or an index assignment doesn't change another
+func copyElements(elements []object.Object) []object.Object {
+	copied := make([]object.Object, len(elements))
+	copy(copied, elements)
+	return copied
+}
+
+func hashField(hash *object.HashMap, name string) object.Object {
+	pair, ok := hash.Get(&object.String{Value: name})
+	if !ok {
+		return nil
+	}
+	return openHashObj(pair.Value)
 }
@@ -0,0 +1,146 @@
+package evaluator
+
+import (
+	"github.com/MYKatz/PLZ/object"
+)
+
+//comparison keeps track of the pairs of containers being compared, so that an array or hash
+//holding itself compares as equal to its twin instead of recursing forever, and of the pairs
+//already found equal, so that containers sharing parts are compared once rather than once per
+//path to them. A pair found unequal ends the whole comparison, so the pairs it assumed equal
+//while in progress are never reused
+type comparison struct {
+	inProgress map[[2]object.Object]bool
+	equalPairs map[[2]object.Object]bool
+}
+
+//objectsEqual is structural equality. Numbers are equal when their values are, whatever mix of
+//integers and floats; strings, booleans and Null by value; arrays when their elements are
+//equal in order; hashes when they have equal values under the same keys, in any order.
+//Values of different types are never equal, and functions are only equal to themselves
+func objectsEqual(left object.Object, right object.Object) bool {
+	return (&comparison{}).equal(left, right)
+}
+
+func (c *comparison) equal(left object.Object, right object.Object) bool {
+	left, right = openHashObj(left), openHashObj(right)
+
+	if isNumber(left) && isNumber(right) {
+		l, lok := left.(*object.Integer)
+		r, rok := right.(*object.Integer)
+		if lok && rok {
+			return l.Value == r.Value
+		}
+		return toFloat(left) == toFloat(right)
+	}
+
+	switch l := left.(type) {
+	case *object.String:
+		r, ok := right.(*object.String)
+		return ok && l.Value == r.Value
+	case *object.Boolean:
+		r, ok := right.(*object.Boolean)
+		return ok && l.Value == r.Value
+	case *object.Null:
+		_, ok := right.(*object.Null)
+		return ok
+	case *object.Array:
+		r, ok := right.(*object.Array)
+		if !ok || len(l.Elements) != len(r.Elements) {
+			return false
+		}
+		if c.knownEqual(l, r) || !c.enter(l, r) {
+			return true
+		}
+		defer c.leave(l, r)
+
+		for i := range l.Elements {
+			if !c.equal(l.Elements[i], r.Elements[i]) {
+				return false
+			}
+		}
+		c.markEqual(l, r)
+		return true
+	case *object.HashMap:
+		r, ok := right.(*object.HashMap)
+		if !ok || l.Len() != r.Len() {
+			return false
+		}
+		if c.knownEqual(l, r) || !c.enter(l, r) {
+			return true
+		}
+		defer c.leave(l, r)
+
+		for _, pair := range l.Items() {
+			other, ok := r.Get(pair.Key.(object.Hashable))
+			if !ok || !c.equal(pair.Value, other.Value) {
+				return false
+			}
+		}
+		c.markEqual(l, r)
+		return true
+	default:
+		return left == right
+	}
+}
+
+//compareArrays orders arrays lexicographically: by their first pair of elements that differ,
+//or by length when one is a prefix of the other
+func compareArrays(operator string, left *object.Array, right *object.Array) object.Object {
+	return (&comparison{}).order(operator, left, right)
+}
+
+func (c *comparison) order(operator string, left *object.Array, right *object.Array) object.Object {
+	if !c.enter(left, right) {
+		return BOOL_FALSE //the arrays are equal as far as this comparison can tell
+	}
+	defer c.leave(left, right)
+
+	for i := 0; i < len(left.Elements) && i < len(right.Elements); i++ {
+		l, r := openHashObj(left.Elements[i]), openHashObj(right.Elements[i])
+		if objectsEqual(l, r) { //a fresh comparison, since a pair found unequal here doesn't end this one
+			continue
+		}
+
+		la, lok := l.(*object.Array)
+		ra, rok := r.(*object.Array)
+		if lok && rok {
+			return c.order(operator, la, ra)
+		}
+		return evalInfixExpression(operator, l, r)
+	}
+
+	if operator == "<" {
+		return nativeBoolToBooleanObject(len(left.Elements) < len(right.Elements))
+	}
+	return nativeBoolToBooleanObject(len(left.Elements) > len(right.Elements))
+}
+
+//enter marks left and right as being compared, reporting false if they already were
+func (c *comparison) enter(left object.Object, right object.Object) bool {
+	key := [2]object.Object{left, right}
+	if c.inProgress[key] {
+		return false
+	}
+	if c.inProgress == nil {
+		c.inProgress = make(map[[2]object.Object]bool)
+	}
+	c.inProgress[key] = true
+	return true
+}
+
+func (c *comparison) leave(left object.Object, right object.Object) {
+	delete(c.inProgress, [2]object.Object{left, right})
+}
+
+//knownEqual reports whether left and right were already found equal
+func (c *comparison) knownEqual(left object.Object, right object.Object) bool {
+	return c.equalPairs[[2]object.Object{left, right}]
+}
+
+func (c *comparison) markEqual(left object.Object, right object.Object) {
+	if c.equalPairs == nil {
+		c.equalPairs = make(map[[2]object.Object]bool)
+	}
+	c.equalPairs[[2]object.Object{left, right}] = true
+}
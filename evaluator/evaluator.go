@@ -5,15 +5,39 @@ import (
 
 	"github.com/MYKatz/PLZ/ast"
 	"github.com/MYKatz/PLZ/object"
+	"github.com/MYKatz/PLZ/token"
 )
 
+//values shared by every program. Nothing changes them, and truthiness goes by value rather
+//than by identity, so they are safe to share between interpreters running at the same time
 var (
 	BOOL_TRUE  = &object.Boolean{Value: true}
 	BOOL_FALSE = &object.Boolean{Value: false}
 	NULL       = &object.Null{}
+	BREAK      = &object.Break{}
+	CONTINUE   = &object.Continue{}
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	var result object.Object
+
+	budget := env.Budget()
+	if err := budget.Step(); err != nil {
+		result = err
+	} else {
+		result = eval(node, env)
+		if err := budget.CheckAllocation(result); err != nil {
+			result = err
+		}
+	}
+
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() && node != nil {
+		err.Pos = node.Pos() //innermost node that produced the error
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
@@ -23,6 +47,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
 		if node.Value {
 			return BOOL_TRUE
@@ -46,6 +72,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalInfixExpression(node.Operator, left, right)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
 	case *ast.ReturnStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
@@ -57,7 +85,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
-		_, ok := builtins[node.Name.Value]
+		_, ok := lookupBuiltin(env, node.Name.Value)
 		if ok {
 			return newError("Invalid let statement: cannot override builtin function %s", node.Name.Value)
 		}
@@ -65,7 +93,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
-		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+		return &object.Function{
+			Name:       node.Name,
+			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Body:       node.Body,
+			Env:        env,
+		}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.CallExpression:
@@ -78,7 +113,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0] //error
 		}
 
-		return applyFunction(function, args)
+		return applyFunction(function, args, node.Pos())
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -97,6 +132,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalIndexExpression(left, index)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	}
 
 	return nil
@@ -127,7 +172,7 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -149,32 +194,23 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 }
 
 func evalBangOperatorExpression(right object.Object) object.Object {
-	switch right {
-	case BOOL_TRUE:
-		return BOOL_FALSE
-	case BOOL_FALSE:
-		return BOOL_TRUE
-	case NULL:
-		return BOOL_TRUE
-	default:
-		return BOOL_FALSE
-	}
+	return nativeBoolToBooleanObject(!isTruthy(right))
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	right = openHashObj(right)
 	switch {
-	case left.Type() == object.HASHOBJ_OBJ && operator == "=":
-		return evalHashReassignment(left, right)
 	case left.Type() == object.HASHOBJ_OBJ:
 		l, ok := left.(*object.HashObject)
 		if !ok {
@@ -183,18 +219,16 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 		return evalInfixExpression(operator, l.Inner, right)
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right): //at least one float; ints are promoted
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
-		if left == right {
-			return BOOL_TRUE
-		}
-		return BOOL_FALSE
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
 	case operator == "!=":
-		if left != right {
-			return BOOL_TRUE
-		}
-		return BOOL_FALSE
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
+	case left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ && (operator == "<" || operator == ">"):
+		return compareArrays(operator, left.(*object.Array), right.(*object.Array))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
@@ -202,16 +236,6 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	}
 }
 
-func evalHashReassignment(left object.Object, right object.Object) object.Object {
-	l, ok := left.(*object.HashObject)
-	if !ok {
-		newError("Object with type HashObject is not hash object..?")
-	}
-
-	l.Hash.Pairs[l.Key] = object.HashPair{Key: l.PlainKey, Value: right}
-	return right
-}
-
 func evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
@@ -224,6 +248,9 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "==":
 		if leftVal == rightVal {
@@ -250,6 +277,54 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	}
 }
 
+func evalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+//toFloat widens an Integer or Float to a float64
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
+func nativeBoolToBooleanObject(value bool) *object.Boolean {
+	if value {
+		return BOOL_TRUE
+	}
+	return BOOL_FALSE
+}
+
 func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
@@ -257,6 +332,14 @@ func evalStringInfixExpression(operator string, left object.Object, right object
 	switch operator {
 	case "+":
 		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -276,8 +359,190 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Body, env)
+
+	err, ok := result.(*object.Error)
+	if !ok || !err.Catchable() {
+		return result
+	}
+
+	if te.Parameter != nil {
+		env.Set(te.Parameter.Value, caughtError(err))
+	}
+	return Eval(te.Handler, env)
+}
+
+//caughtError is the value a sorry block sees for err
+func caughtError(err *object.Error) *object.HashMap {
+	hash := object.NewHashMap()
+	hash.Set(&object.String{Value: "message"}, &object.String{Value: err.Message})
+	hash.Set(&object.String{Value: "type"}, &object.String{Value: err.ErrorType()})
+	return hash
+}
+
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		result := Eval(ws.Body, env)
+		if result, stop := loopControl(result); stop {
+			return result
+		}
+	}
+}
+
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	items, err := iterationItems(openHashObj(iterable))
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		env.Set(fs.Variable.Value, item)
+
+		result := Eval(fs.Body, env)
+		if result, stop := loopControl(result); stop {
+			return result
+		}
+	}
+
+	return nil
+}
+
+//loopControl decides what a loop does with the result of one pass through its body:
+//stop is true on break, return or error, and result is what the loop statement evaluates to
+func loopControl(result object.Object) (object.Object, bool) {
+	if result == nil {
+		return nil, false
+	}
+
+	switch result.Type() {
+	case object.BREAK_OBJ:
+		return nil, true
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return result, true
+	}
+	return nil, false
+}
+
+//iterationItems lists what a for loop visits: array elements, string characters or hash keys
+func iterationItems(iterable object.Object) ([]object.Object, *object.Error) {
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		items := make([]object.Object, len(iterable.Elements))
+		copy(items, iterable.Elements) //the body may reassign elements while we iterate
+		return items, nil
+	case *object.String:
+		items := []object.Object{}
+		for _, ch := range iterable.Value {
+			items = append(items, &object.String{Value: string(ch)})
+		}
+		return items, nil
+	case *object.HashMap:
+		items := []object.Object{}
+		for _, pair := range iterable.Items() {
+			items = append(items, pair.Key)
+		}
+		return items, nil
+	default:
+		return nil, newError("cannot iterate over %s", iterable.Type())
+	}
+}
+
+func evalAssignExpression(ae *ast.AssignExpression, env *object.Environment) object.Object {
+	value := Eval(ae.Value, env)
+	if isError(value) {
+		return value
+	}
+	value = openHashObj(value)
+
+	switch target := ae.Target.(type) {
+	case *ast.Identifier:
+		current, ok := env.Get(target.Value)
+		if !ok {
+			return newError("cannot assign to undefined variable: %s", target.Value)
+		}
+		value = applyAssignOperator(ae.Operator, current, value)
+		if isError(value) {
+			return value
+		}
+		env.Assign(target.Value, value)
+		return value
+	case *ast.IndexExpression:
+		container := Eval(target.Left, env)
+		if isError(container) {
+			return container
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexAssignment(ae.Operator, openHashObj(container), openHashObj(index), value)
+	default:
+		return newError("cannot assign to %s", ae.Target.String())
+	}
+}
+
+//applyAssignOperator combines the current value with the new one for compound operators like +=
+func applyAssignOperator(operator string, current object.Object, value object.Object) object.Object {
+	switch operator {
+	case "be", "=":
+		return value
+	default:
+		return evalInfixExpression(operator[:len(operator)-1], openHashObj(current), value)
+	}
+}
+
+func evalIndexAssignment(operator string, container object.Object, index object.Object, value object.Object) object.Object {
+	switch container := container.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("index type not supported: %s", index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(container.Elements)) {
+			return newError("index out of range: %d", i.Value)
+		}
+		value = applyAssignOperator(operator, container.Elements[i.Value], value)
+		if isError(value) {
+			return value
+		}
+		container.Set(int(i.Value), value)
+		return value
+	case *object.HashMap:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("not hashable: %s", index.Type())
+		}
+		current := object.Object(NULL)
+		if pair, ok := container.Get(key); ok {
+			current = pair.Value
+		}
+		value = applyAssignOperator(operator, current, value)
+		if isError(value) {
+			return value
+		}
+		container.Set(key, value)
+		return value
+	default:
+		return newError("index assignment not supported: %s", container.Type())
+	}
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	builtin, ok := builtins[node.Value]
+	builtin, ok := lookupBuiltin(env, node.Value)
 	if ok {
 		return builtin
 	}
@@ -290,6 +555,15 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 
 }
 
+func lookupBuiltin(env *object.Environment, name string) (*object.BuiltIn, bool) {
+	table := env.Builtins()
+	if table == nil {
+		table = defaultBuiltins
+	}
+	builtin, ok := table[name]
+	return builtin, ok
+}
+
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
@@ -304,20 +578,44 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	switch fn := fn.(type) {
+//applyFunction calls fn. Errors raised inside a PLZ function record the call in their stack trace
+func applyFunction(fn object.Object, args []object.Object, callSite token.Position) object.Object {
+	switch fn := openHashObj(fn).(type) {
 	case *object.BuiltIn: //putting this case first prevents overriding of builtin functions
-		return fn.Fn(args...)
+		return applyBuiltin(fn, args)
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		if err := checkArity(requiredParameters(fn.Defaults), len(fn.Parameters), fn.Rest != nil, len(args)); err != nil {
+			return err
+		}
+
+		budget := fn.Env.Budget()
+		if err := budget.Enter(); err != nil {
+			return err
+		}
+		defer budget.Leave()
+
+		extendedEnv, evaluated := extendFunctionEnv(fn, args)
+		if evaluated == nil {
+			evaluated = unwrapReturnValue(Eval(fn.Body, extendedEnv))
+		}
+		if err, ok := evaluated.(*object.Error); ok {
+			err.Stack = append(err.Stack, object.StackFrame{Function: object.FunctionName(fn.Name), CallSite: callSite})
+		}
+		return evaluated
 
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+//applyBuiltin calls fn with values read out of hashes unwrapped, so builtins only ever see plain values
+func applyBuiltin(fn *object.BuiltIn, args []object.Object) object.Object {
+	for i, arg := range args {
+		args[i] = openHashObj(arg)
+	}
+	return fn.Fn(args...)
+}
+
 func evalIndexExpression(left object.Object, index object.Object) object.Object {
 	//left = openHashObj(left)
 	//index = openHashObj(left)
@@ -353,20 +651,20 @@ func evalHashIndexExpression(hash object.Object, index object.Object) object.Obj
 		return newError("not hashable: %s", index.Type())
 	}
 
-	pair, ok := hashObj.Pairs[key.HashKey()]
+	pair, ok := hashObj.Get(key)
 	if !ok {
 		return NULL
 	}
 
-	obj := object.HashObject{Inner: pair.Value, Hash: hashObj, Key: key.HashKey(), PlainKey: index}
+	obj := object.HashObject{Inner: pair.Value, Hash: hashObj, PlainKey: index}
 	return &obj
 }
 
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHashMap()
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
 		if isError(key) {
 			return key
 		}
@@ -376,26 +674,66 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return newError("not hashable: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(pair.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
+	}
+
+	return hash
+}
+
+//checkArity reports a call passing fewer than required or more than max arguments. Variadic
+//functions take any number past required
+func checkArity(required int, max int, variadic bool, received int) *object.Error {
+	switch {
+	case received >= required && (variadic || received <= max):
+		return nil
+	case variadic:
+		return newError("wrong number of arguments: expected at least %d, received %d", required, received)
+	case required == max:
+		return newError("wrong number of arguments: expected %d, received %d", required, received)
+	default:
+		return newError("wrong number of arguments: expected %d to %d, received %d", required, max, received)
 	}
+}
 
-	return &object.HashMap{Pairs: pairs}
+func requiredParameters(defaults []ast.Expression) int {
+	required := 0
+	for required < len(defaults) && defaults[required] == nil {
+		required++
+	}
+	return required
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+//extendFunctionEnv binds the arguments of a call, evaluating the defaults of any parameters
+//left out in order so they can refer to earlier ones. A failing default is returned as an error
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+		value := Eval(fn.Defaults[paramIdx], env)
+		if isError(value) {
+			return env, value
+		}
+		env.Set(param.Value, value)
 	}
 
-	return env
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -424,13 +762,11 @@ func openHashObj(obj object.Object) object.Object {
 
 //TODO: make certain other things truthy ie 0, empty string, etc.
 func isTruthy(obj object.Object) bool {
-	switch obj {
-	case NULL:
-		return false
-	case BOOL_TRUE:
-		return true
-	case BOOL_FALSE:
+	switch obj := obj.(type) {
+	case *object.Null:
 		return false
+	case *object.Boolean: //by value, since host code can make booleans of its own
+		return obj.Value
 	default:
 		return true
 	}
@@ -166,6 +166,9 @@ func TestErrorHandling(t *testing.T) {
 		{"-True plz", "unknown operator: -BOOLEAN"},
 		{"True + False plz", "unknown operator: BOOLEAN + BOOLEAN"},
 		{"foo plz", "identifier not found: foo"},
+		{"1 / 0 plz", "division by zero"},
+		{"assign([1, 2], 2, 3) plz", "index out of range: 2"},
+		{`assign([1, 2], "0", 3) plz`, "Invalid second argument. Expected integer, received STRING"},
 	}
 
 	for _, tt := range tests {
@@ -279,6 +282,8 @@ func TestLenBuiltinFunction(t *testing.T) {
 		{`len("")`, 0},
 		{`len(1)`, "Invalid argument to len, received INTEGER"},
 		{`len("foo", "bar")`, "Incorrect number of arguments. Expected 1, received 2"},
+		{`let h be {"s": "abc"} plz len(h["s"])`, 3},
+		{`let h be {"len": len} plz h.len("ab")`, 2},
 	}
 
 	for _, tt := range tests {
@@ -367,22 +372,54 @@ func TestHashLiterals(t *testing.T) {
 		t.Fatalf("Eval did not return hashmap, got %T", evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		BOOL_TRUE.HashKey():                        100,
-		BOOL_FALSE.HashKey():                       -1,
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{BOOL_TRUE, 100},
+		{BOOL_FALSE, -1},
+	}
+
+	if res.Len() != len(expected) {
+		t.Fatalf("Incorrect number of pairs. Expected %d, received %d", len(expected), res.Len())
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := res.Pairs[expectedKey]
+	for i, item := range res.Items() {
+		if item.Key.Inspect() != expected[i].key.Inspect() {
+			t.Errorf("Pair %d out of order. Expected key %s, received %s", i, expected[i].key.Inspect(), item.Key.Inspect())
+		}
+
+		pair, ok := res.Get(expected[i].key)
 		if !ok {
 			t.Errorf("No pair")
 		}
 
-		testIntegerObject(t, pair.Value, expectedValue)
+		testIntegerObject(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestHashLiteralOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let h be {"z": 1, "a": 2, "m": 3} plz h["a"] be 5 plz h["b"] be 6 plz h`, "{z:1,a:5,m:3,b:6,}"},
+		{`let keys be [] plz for k in {"z": 1, 2: 2, "a": 3} please keys be append(keys, k) plz thanks keys`, "[z, 2, a]"},
+		{`let log be [] plz
+		let note be function(x) please log be append(log, x) plz x thanks plz
+		{note("b"): note(1), note("a"): note(2)} plz
+		log`, "[b, 1, a, 2]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("Incorrect result for %q. Expected %s, received %s", tt.input, tt.expected, evaluated.Inspect())
+		}
 	}
 }
 
@@ -408,6 +445,28 @@ func TestArrayAssignFunction(t *testing.T) {
 	}
 }
 
+func TestArrayBuiltinsDontShareArrays(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a be [1, 2, 3] plz let r be rest(a) plz assign(r, 0, 9) plz r[1] be 8 plz a", "[1, 2, 3]"},
+		{"let a be [1, 2, 3] plz let b be assign(a, 0, 9) plz b[1] be 8 plz a", "[9, 8, 3]"},
+		{"let a be append(append(append([], 1), 2), 3) plz let b be append(a, 9) plz let c be append(a, 10) plz b[0] be 100 plz [a, b, c]", "[[1, 2, 3], [100, 2, 3, 9], [1, 2, 3, 10]]"},
+		{"let a be append(append([], 1), 2) plz let b be append(a, 3) plz a[0] be 7 plz assign(b, 1, 8) plz let c be append(a, 4) plz [a, b, c]", "[[7, 2], [1, 8, 3], [7, 2, 4]]"},
+		{"first([])", "Null"},
+		{"peek([])", "Null"},
+		{"rest([])", "[]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("Incorrect result for %q. Expected %s, received %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestHashAssignFunction(t *testing.T) {
 	input := `
 	let a be {"one": 1, "two": 3} plz
@@ -421,9 +480,7 @@ func TestHashAssignFunction(t *testing.T) {
 		t.Fatalf("Eval did not return hashmap, got %T", evaluated)
 	}
 
-	expectedKey := (&object.String{Value: "two"}).HashKey()
-
-	pair, ok := res.Pairs[expectedKey]
+	pair, ok := res.Get(&object.String{Value: "two"})
 	if !ok {
 		t.Errorf("No pair found")
 	}
@@ -445,9 +502,7 @@ func TestMapReassign(t *testing.T) {
 		t.Fatalf("Eval did not return hashmap, got %T", evaluated)
 	}
 
-	expectedKey := (&object.String{Value: "two"}).HashKey()
-
-	pair, ok := res.Pairs[expectedKey]
+	pair, ok := res.Get(&object.String{Value: "two"})
 	if !ok {
 		t.Errorf("No pair found")
 	}
@@ -477,3 +532,494 @@ func TestMapAccessWithPeriod(t *testing.T) {
 
 	testIntegerObject(t, integ, 2)
 }
+
+func TestErrorPositions(t *testing.T) {
+	input := "let a be 5 plz\nlet b be a + True plz"
+
+	l := lexer.NewFileLexer("script.plz", input)
+	p := parser.NewParser(l)
+	program := p.ParseProgram()
+	evaluated := Eval(program, object.NewEnvironment())
+
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. Received %T", evaluated)
+	}
+
+	expected := "script.plz:2:10: Error: type mismatch: INTEGER + BOOLEAN"
+	if err.Inspect() != expected {
+		t.Errorf("wrong error output. Expected %q, received %q", expected, err.Inspect())
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"2.5", 2.5},
+		{"-1.5", -1.5},
+		{"1e2", 100},
+		{"1.5 + 1", 2.5},
+		{"1 + 1.5", 2.5},
+		{"3 / 2.0", 1.5},
+		{"0.5 * 4", 2},
+		{"10 - 0.25", 9.75},
+		{"float(3)", 3},
+		{`float("2.5")`, 2.5},
+		{"round(3.14159, 2)", 3.14},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func testFloatObject(t *testing.T, evaluated object.Object, expected float64) bool {
+	result, ok := evaluated.(*object.Float)
+	if !ok {
+		t.Errorf("Object is not Float. Received %T (%+v)", evaluated, evaluated)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("Object has wrong value. Expected %g, received %g", expected, result.Value)
+		return false
+	}
+	return true
+}
+
+func TestMixedNumberComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 == 1.0", true},
+		{"1.5 != 1", true},
+		{"2 < 2.5", true},
+		{"2.5 > 3", false},
+		{"0.1 + 0.2 > 0.3", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestNumberConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"int(3.9)", 3},
+		{"int(-3.9)", -3},
+		{`int("42")`, 42},
+		{"round(2.5)", 3},
+		{"round(-2.5)", -3},
+		{"round(7)", 7},
+		{`int("4.2")`, `Could not convert "4.2" to int`},
+		{`float("pi")`, `Could not convert "pi" to float`},
+		{`round("1")`, "Invalid first argument to round, received STRING"},
+		{"int(1, 2)", "Incorrect number of arguments. Expected 1, received 2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error, received %T", evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("incorrect error message, expected %q, received %q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestFloatHashKeys(t *testing.T) {
+	input := `let h be {1: "one", 2.5: "two and a half"} plz
+	[h[1.0], h[2.5]]`
+
+	evaluated := testEval(input)
+	arr, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("Eval did not return array, got %T", evaluated)
+	}
+
+	if arr.Elements[0].Inspect() != "one" || arr.Elements[1].Inspect() != "two and a half" {
+		t.Errorf("Incorrect lookups, received %s", arr.Inspect())
+	}
+}
+
+func TestWhileLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let i be 0 plz while (i < 5) please let i be i + 1 plz thanks i", 5},
+		{"let i be 0 plz while (True) please let i be i + 1 plz if (i == 3) please break plz thanks thanks i", 3},
+		{`let i be 0 plz let s be 0 plz
+		while (i < 5) please
+			let i be i + 1 plz
+			if (i == 2) please continue plz thanks
+			let s be s + i plz
+		thanks
+		s`, 13},
+		{"let f be function() please while (True) please return 7 plz thanks thanks plz f()", 7},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let s be 0 plz for x in [1, 2, 3] please let s be s + x plz thanks s", 6},
+		{`let n be 0 plz for c in "héllo" please let n be n + 1 plz thanks n`, 5},
+		{`let s be 0 plz for k in {1: "a", 2: "b", 3: "c"} please let s be s + k plz thanks s`, 6},
+		{"let s be 0 plz for x in [1, 2, 3, 4] please if (x == 2) please continue thanks if (x == 4) please break thanks let s be s + x plz thanks s", 4},
+		{"for x in [] please 1 + True thanks", nil},
+		{"for x in 5 please x thanks", "cannot iterate over INTEGER"},
+		{"for x in [1] please x + True thanks", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error, received %T", evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("incorrect error message, expected %q, received %q", expected, errObj.Message)
+			}
+		case nil:
+			if evaluated != nil {
+				t.Errorf("expected no value, received %s", evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestLoopOverLargeArray(t *testing.T) {
+	input := `let nums be [] plz
+	let i be 0 plz
+	while (i < 100000) please
+		let nums be append(nums, i) plz
+		let i be i + 1 plz
+	thanks
+	let sum be 0 plz
+	for n in nums please let sum be sum + n plz thanks
+	sum`
+
+	testIntegerObject(t, testEval(input), 4999950000)
+}
+
+func TestReassignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x be 1 plz x be x + 1 plz x", 2},
+		{"let x be 1 plz x = 5 plz x", 5},
+		{"let x be 1 plz x += 4 plz x -= 1 plz x *= 3 plz x /= 2 plz x", 6},
+		{"let a be 1 plz let b be 1 plz a be b be 7 plz a + b", 14},
+		{`let counter be function() please
+			let count be 0 plz
+			return function() please count += 1 plz return count plz thanks plz
+		thanks plz
+		let next be counter() plz
+		next() plz next() plz next()`, 3},
+		{"let x be 1 plz let f be function() please let x be 10 plz x += 1 plz thanks plz f() plz x", 1},
+		{"let arr be [1, 2, 3] plz arr[1] += 10 plz arr[1] be arr[1] * 2 plz arr[1]", 24},
+		{`let m be {"n": 1} plz m["n"] += 1 plz m.n *= 5 plz m["n"]`, 10},
+		{`let m be {} plz m["new"] be 3 plz m["new"]`, 3},
+		{"y be 1 plz", "cannot assign to undefined variable: y"},
+		{"let arr be [1] plz arr[3] be 1 plz", "index out of range: 3"},
+		{"let x be 1 plz x += True plz", "type mismatch: INTEGER + BOOLEAN"},
+		{`let s be "a" plz s[0] be "b" plz`, "index assignment not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := unwrapHashObject(testEval(tt.input))
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error, received %T", evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("incorrect error message, expected %q, received %q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f be function(a, b) please a + b thanks plz f(1)", "wrong number of arguments: expected 2, received 1"},
+		{"let f be function(a, b) please a + b thanks plz f(1, 2, 3)", "wrong number of arguments: expected 2, received 3"},
+		{"let f be function() please 1 thanks plz f(1)", "wrong number of arguments: expected 0, received 1"},
+		{"let f be function(a, b be 10) please a + b thanks plz f(1)", 11},
+		{"let f be function(a, b be 10) please a + b thanks plz f(1, 2)", 3},
+		{"let f be function(a, b be 10) please a + b thanks plz f()", "wrong number of arguments: expected 1 to 2, received 0"},
+		{"let f be function(a be 1, b be a * 2) please a + b thanks plz f(5)", 15},
+		{"let f be function(a be 1 + True) please a thanks plz f(2)", 2},
+		{"let f be function(a be 1 + True) please a thanks plz f()", "type mismatch: INTEGER + BOOLEAN"},
+		{"let f be function(first, ...others) please len(others) thanks plz f(1, 2, 3)", 2},
+		{"let f be function(first, ...others) please len(others) thanks plz f(1)", 0},
+		{"let f be function(first, ...others) please others[1] thanks plz f(1, 2, 3)", 3},
+		{"let f be function(first, ...others) please first thanks plz f()", "wrong number of arguments: expected at least 1, received 0"},
+		{"let f be function(a be 5, ...others) please a + len(others) thanks plz f()", 5},
+		{"let f be function(...all) please let g be function() please len(all) thanks plz g() thanks plz f(1, 2)", 2},
+		{"let f be function(a, n be function() please a thanks) please n() thanks plz f(7)", 7},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q, received %T", tt.input, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("incorrect error message, expected %q, received %q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestArityErrorsHaveNoCalleeFrame(t *testing.T) {
+	input := "let f be function(a) please a thanks plz\nlet g be function() please f() thanks plz\ng()"
+
+	err, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+
+	//the failed call happened inside g; f itself was never entered
+	if len(err.Stack) != 1 || err.Stack[0].Function != "g" {
+		t.Errorf("Incorrect stack. Expected a single frame for g, received %+v", err.Stack)
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] != [1, 2, 3]", true},
+		{"[] == []", true},
+		{`[1, [2, {"a": 1.0}]] == [1.0, [2, {"a": 1}]]`, true},
+		{`{"a": 1, "b": 2} == {"b": 2, "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{"let n be if (False) please 1 thanks plz n == if (False) please 2 thanks", true},
+		{"let n be if (False) please 1 thanks plz n == 0", false},
+		{`1 == "1"`, false},
+		{`1 != "1"`, true},
+		{"[1] == {0: 1}", false},
+		{"True == 1", false},
+		{`"" == False`, false},
+		{"let f be function() please 1 thanks plz f == f", true},
+		{"function() please 1 thanks == function() please 1 thanks", false},
+		{`let h be {"a": [1, 2]} plz h["a"] == [1, 2]`, true},
+		{"let a be [1] plz assign(a, 0, a) plz let b be [1] plz assign(b, 0, b) plz a == b", true},
+		{"let a be [1] plz let b be [1] plz let i be 0 plz while (i < 30) please a be [a, a] plz b be [b, b] plz i += 1 plz thanks a == b", true},
+		{`let a be {"x": 1} plz let b be {"x": 1} plz let i be 0 plz while (i < 30) please a be {"l": a, "r": a} plz b be {"l": b, "r": b} plz i += 1 plz thanks a != b`, false},
+		{"let a be [1] plz let b be [2] plz let i be 0 plz while (i < 30) please a be [a, a] plz b be [b, b] plz i += 1 plz thanks a < b", true},
+		{`"abc" < "abd"`, true},
+		{`"abc" > "ab"`, true},
+		{`"B" < "a"`, true},
+		{`"" < "a"`, true},
+		{"[1, 2] < [1, 3]", true},
+		{"[1, 2] > [1, 3]", false},
+		{"[1, 2] < [1, 2, 0]", true},
+		{"[1, 2] < [1, 2]", false},
+		{"[2] > [1, 5]", true},
+		{`[[1], "b"] < [[1], "c"]`, true},
+		{"[[1, 2]] > [[1, 1, 9]]", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !testBooleanObject(t, evaluated, tt.expected) {
+			t.Errorf("Incorrect result for %q", tt.input)
+		}
+	}
+}
+
+func TestComparisonErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, "a"] < [1, 2]`, "type mismatch: STRING < INTEGER"},
+		{`"a" < 1`, "type mismatch: STRING < INTEGER"},
+		{`{"a": 1} < {"a": 2}`, "unknown operator: HASH < HASH"},
+		{`"a" - "b"`, "unknown operator: STRING - STRING"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("No error for %q", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("Incorrect error message. Expected %q, received %q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func unwrapHashObject(obj object.Object) object.Object {
+	if ho, ok := obj.(*object.HashObject); ok {
+		return ho.Inner
+	}
+	return obj
+}
+
+func TestStackTraces(t *testing.T) {
+	input := `let inner be function(x) please
+	x + True
+thanks plz
+let outer be function(y) please
+	inner(y) plz
+thanks plz
+let apply be function(f) please f() thanks plz
+apply(function() please outer(1) thanks)`
+
+	evaluated := testEval(input)
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. Received %T", evaluated)
+	}
+
+	expected := []struct {
+		function string
+		line     int
+		column   int
+	}{
+		{"inner", 5, 2},
+		{"outer", 8, 25},
+		{"<anonymous>", 7, 33},
+		{"apply", 8, 1},
+	}
+
+	if len(err.Stack) != len(expected) {
+		t.Fatalf("Stack has wrong length. Expected %d, received %d: %+v", len(expected), len(err.Stack), err.Stack)
+	}
+	for i, frame := range expected {
+		received := err.Stack[i]
+		if received.Function != frame.function || received.CallSite.Line != frame.line || received.CallSite.Column != frame.column {
+			t.Errorf("Frame %d incorrect. Expected %s at %d:%d, received %s at %s", i, frame.function, frame.line, frame.column, received.Function, received.CallSite)
+		}
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"try please 1 thanks sorry please 2 thanks", 1},
+		{"try please 1 + True thanks sorry please 2 thanks", 2},
+		{"try please 1 + True thanks sorry (err) please err.message thanks", "type mismatch: INTEGER + BOOLEAN"},
+		{"try please undefined thanks sorry (err) please err.type thanks", "NameError"},
+		{"try please [1][5] be 0 thanks sorry (err) please err.type thanks", "IndexError"},
+		{`try please int("x") thanks sorry (err) please err.type thanks`, "ValueError"},
+		{`try please complain("out of cheese") thanks sorry (err) please err.type + ": " + err.message thanks`, "Error: out of cheese"},
+		{`try please raise("odd", "ValueError") thanks sorry (err) please err.type thanks`, "ValueError"},
+		{`let f be function() please complain("deep") plz 1 thanks plz
+		let g be function() please f() + 1 thanks plz
+		try please g() thanks sorry (err) please err.message thanks`, "deep"},
+		{`let f be function() please
+			try please return 1 thanks sorry please 2 thanks plz
+			3
+		thanks plz f()`, 1},
+		{`let n be 0 plz
+		for x in [1, 2, 3] please
+			try please if (x == 2) please break thanks let n be n + x plz thanks sorry please 0 thanks
+		thanks
+		n`, 1},
+		{`try please
+			try please complain("inner") thanks sorry (err) please complain(err.message + " again") thanks
+		thanks sorry (err) please err.message thanks`, "inner again"},
+		{`try please complain("a", "KeyError") thanks sorry (err) please complain(err) thanks`, "a"},
+		{`complain("uncaught")`, "uncaught"},
+		{`complain(1)`, "Invalid argument to complain, received INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := unwrapHashObject(testEval(tt.input))
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch result := evaluated.(type) {
+			case *object.String:
+				if result.Value != expected {
+					t.Errorf("Incorrect result for %q. Expected %q, received %q", tt.input, expected, result.Value)
+				}
+			case *object.Error:
+				if result.Message != expected {
+					t.Errorf("Incorrect error for %q. Expected %q, received %q", tt.input, expected, result.Message)
+				}
+			default:
+				t.Errorf("Unexpected result for %q: %T", tt.input, evaluated)
+			}
+		}
+	}
+}
+
+func TestLimitErrorsAreNotCaught(t *testing.T) {
+	input := `let f be function() please f() thanks plz try please f() thanks sorry please 1 thanks`
+
+	l := lexer.NewLexer(input)
+	p := parser.NewParser(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	env.SetBudget(object.NewBudget(object.Limits{MaxDepth: 50}))
+
+	err, ok := Eval(program, env).(*object.Error)
+	if !ok {
+		t.Fatalf("limit error was caught")
+	}
+	if err.ErrorType() != object.LIMIT_ERROR {
+		t.Errorf("Incorrect error type. Expected %s, received %s", object.LIMIT_ERROR, err.ErrorType())
+	}
+}
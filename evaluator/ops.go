@@ -0,0 +1,66 @@
+package evaluator
+
+import (
+	"github.com/MYKatz/PLZ/object"
+	"github.com/MYKatz/PLZ/token"
+)
+
+//The functions below expose the evaluator's value semantics so that the vm package computes
+//exactly what Eval does for the same operation, errors included.
+
+func EvalPrefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+func EvalInfix(operator string, left object.Object, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+func EvalIndex(left object.Object, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+//ApplyAssignOperator computes the value stored by an assignment such as x += value
+func ApplyAssignOperator(operator string, current object.Object, value object.Object) object.Object {
+	return applyAssignOperator(operator, current, openHashObj(value))
+}
+
+//AssignIndex performs container[index] <operator> value, returning the stored value
+func AssignIndex(operator string, container object.Object, index object.Object, value object.Object) object.Object {
+	return evalIndexAssignment(operator, openHashObj(container), openHashObj(index), openHashObj(value))
+}
+
+func IterationItems(iterable object.Object) ([]object.Object, *object.Error) {
+	return iterationItems(openHashObj(iterable))
+}
+
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+//LookupBuiltin finds a builtin in the table used by environments that weren't given their own
+func LookupBuiltin(name string) (*object.BuiltIn, bool) {
+	builtin, ok := defaultBuiltins[name]
+	return builtin, ok
+}
+
+func ApplyBuiltin(fn *object.BuiltIn, args []object.Object) object.Object {
+	return applyBuiltin(fn, args)
+}
+
+//ApplyFunction calls fn, a function or builtin, with args from outside any program, as host
+//code does. A function runs within the budget of the environment it was defined in
+func ApplyFunction(fn object.Object, args []object.Object) object.Object {
+	return applyFunction(fn, args, token.Position{})
+}
+
+//CheckArity reports an error when a function taking required to max arguments, or at least
+//required if it is variadic, is called with received arguments
+func CheckArity(required int, max int, variadic bool, received int) *object.Error {
+	return checkArity(required, max, variadic, received)
+}
+
+//CaughtError converts an error into the value a sorry block binds it to
+func CaughtError(err *object.Error) object.Object {
+	return caughtError(err)
+}
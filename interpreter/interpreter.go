@@ -2,33 +2,348 @@ package interpreter
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
 
+	"github.com/MYKatz/PLZ/ast"
+	"github.com/MYKatz/PLZ/compiler"
 	"github.com/MYKatz/PLZ/evaluator"
 	"github.com/MYKatz/PLZ/lexer"
 	"github.com/MYKatz/PLZ/object"
 	"github.com/MYKatz/PLZ/parser"
+	"github.com/MYKatz/PLZ/vm"
 )
 
-func Interpret(in string) {
-	out := ""
-	l := lexer.NewLexer(in)
-	p := parser.NewParser(l)
+//process exit codes returned by Run and RunFile
+const (
+	ExitOK          = 0
+	ExitError       = 1 //the script couldn't be read or raised an uncaught error
+	ExitSyntaxError = 2
+)
 
-	prog := p.ParseProgram()
+//Engine selects how programs are executed
+type Engine string
+
+const (
+	EngineEval Engine = "eval" //walk the syntax tree
+	EngineVM   Engine = "vm"   //compile to bytecode and run it on the virtual machine
+)
+
+//Options configures how programs are run
+type Options struct {
+	Engine Engine
+	Limits object.Limits
+
+	Stdout    io.Writer                //where print writes, os.Stdout if nil
+	MaxOutput int                      //print cuts a value's text short past this many bytes, no limit if 0
+	Stderr    io.Writer                //where Run and RunFile report errors, os.Stderr if nil
+	Stdin     io.Reader                //where input reads from, os.Stdin if nil
+	Globals   map[string]object.Object //variables defined before the first program runs
+}
+
+//DefaultOptions uses the tree-walking evaluator, bounding only call depth
+func DefaultOptions() Options {
+	return Options{Engine: EngineEval, Limits: object.DefaultLimits()}
+}
+
+//withDefaults fills in the standard streams left unset
+func (opts Options) withDefaults() Options {
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+	if opts.Stdin == nil {
+		opts.Stdin = os.Stdin
+	}
+	return opts
+}
+
+//Interpreter runs PLZ programs with its own builtins and output. Programs run one after another
+//share variables, so a later program can use what an earlier one defined.
+//
+//An Interpreter runs one program or call at a time and must not be used from several goroutines
+//at once. Separate Interpreters share no state that programs can change, so any number of them
+//can run in parallel
+type Interpreter struct {
+	opts     Options
+	builtins map[string]*object.BuiltIn
+
+	env *object.Environment //state of the tree-walking evaluator
 
-	env := object.NewEnvironment()
+	symbols   *compiler.SymbolTable //state of the virtual machine
+	constants []object.Object
+	globals   []object.Object
+}
+
+//SyntaxError is returned by Run for a program that doesn't parse
+type SyntaxError struct {
+	Errors []string //one message per problem, each starting with its position
+}
+
+func (e *SyntaxError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+//RuntimeError is returned by Run for a program that raises an error it doesn't catch
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Err.Traceback()
+}
 
+func New(opts Options) *Interpreter {
+	opts = opts.withDefaults()
+	i := &Interpreter{opts: opts, builtins: evaluator.NewBuiltins(opts.Stdout, opts.Stdin, opts.MaxOutput)}
+
+	if opts.Engine == EngineVM {
+		i.symbols = compiler.NewSymbolTable()
+		i.constants = []object.Object{}
+		i.globals = make([]object.Object, vm.GlobalsSize)
+	} else {
+		i.env = object.NewEnvironment()
+		i.env.SetBuiltins(i.builtins)
+	}
+
+	for name, value := range opts.Globals {
+		i.Set(name, value)
+	}
+	return i
+}
+
+//Set defines the global variable name for the programs run from now on. Arrays and hashes are
+//copied, so programs can't change the host's value or a value given to other interpreters
+func (i *Interpreter) Set(name string, value object.Object) {
+	value = copyValue(value, map[object.Object]object.Object{})
+	if i.opts.Engine == EngineVM {
+		i.globals[i.symbols.Define(name).Index] = value
+		return
+	}
+	i.env.Set(name, value)
+}
+
+//Register makes fn callable from this interpreter's programs as the builtin name, replacing
+//any builtin already called that. Values read out of hashes are passed to fn as plain values
+func (i *Interpreter) Register(name string, fn object.BuiltinFunction) {
+	i.builtins[name] = &object.BuiltIn{Fn: fn}
+}
+
+//RegisterModule groups functions under the global variable name, so that programs call them
+//as name.function(...)
+func (i *Interpreter) RegisterModule(name string, functions map[string]object.BuiltinFunction) {
+	names := make([]string, 0, len(functions))
+	for fnName := range functions {
+		names = append(names, fnName)
+	}
+	sort.Strings(names) //so the module prints the same way every time
+
+	module := object.NewHashMap()
+	for _, fnName := range names {
+		module.Set(&object.String{Value: fnName}, &object.BuiltIn{Fn: functions[fnName]})
+	}
+	i.Set(name, module)
+}
+
+//Run runs src and returns the value of its last statement, which is nil for statements such as
+//let that have none. The error is a *SyntaxError or *RuntimeError
+func (i *Interpreter) Run(src string) (object.Object, error) {
+	return i.run("", src)
+}
+
+func (i *Interpreter) run(filename string, src string) (object.Object, error) {
+	p := parser.NewParser(lexer.NewFileLexer(filename, src))
+	prog := p.ParseProgram()
 	if len(p.Errors()) != 0 {
-		out += "\tOops, there were some errors: \n"
-		for _, e := range p.Errors() {
-			out += "\t " + e + "\n"
+		return nil, &SyntaxError{Errors: p.Errors()}
+	}
+
+	return outcome(i.execute(prog))
+}
+
+//outcome separates a raised error from the value produced by a program or call
+func outcome(result object.Object) (object.Object, error) {
+	if ho, ok := result.(*object.HashObject); ok {
+		result = ho.Inner
+	}
+	if err, ok := result.(*object.Error); ok {
+		return nil, &RuntimeError{Err: err}
+	}
+	return result, nil
+}
+
+//Get returns the value of the global variable name, reporting false if no program defined it
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	if i.opts.Engine == EngineVM {
+		symbol, ok := i.symbols.Resolve(name)
+		if !ok || i.globals[symbol.Index] == nil {
+			return nil, false
+		}
+		return i.globals[symbol.Index], true
+	}
+	return i.env.Get(name)
+}
+
+//Call calls the function held by the global variable name with args. The error is a *RuntimeError
+func (i *Interpreter) Call(name string, args ...object.Object) (object.Object, error) {
+	fn, ok := i.Get(name)
+	if !ok {
+		return nil, &RuntimeError{Err: &object.Error{Message: "identifier not found: " + name}}
+	}
+	return i.CallFunction(fn, args...)
+}
+
+//CallFunction calls fn with args. fn can be any function value from this interpreter's programs,
+//such as one a script passed to a registered builtin, kept to be called later. Like a program,
+//the call gets limits of its own
+func (i *Interpreter) CallFunction(fn object.Object, args ...object.Object) (object.Object, error) {
+	budget := object.NewBudget(i.opts.Limits)
+
+	if i.opts.Engine == EngineVM {
+		bytecode := &compiler.Bytecode{Constants: i.constants, GlobalNames: i.symbols.Names()}
+		machine := vm.NewWithGlobals(bytecode, i.globals)
+		machine.SetBudget(budget)
+		return outcome(machine.Call(fn, args...))
+	}
+
+	i.env.SetBudget(budget)
+	return outcome(evaluator.ApplyFunction(fn, args))
+}
+
+//Interpret runs src and writes its result, or what went wrong, to the interpreter's stdout
+func (i *Interpreter) Interpret(src string) {
+	result, err := i.Run(src)
+
+	switch err := err.(type) {
+	case *SyntaxError:
+		io.WriteString(i.opts.Stdout, "\tOops, there were some errors: \n")
+		for _, e := range err.Errors {
+			io.WriteString(i.opts.Stdout, "\t "+e+"\n")
+		}
+	case *RuntimeError:
+		io.WriteString(i.opts.Stdout, err.Error()+"\n")
+	default:
+		if result != nil {
+			io.WriteString(i.opts.Stdout, result.Inspect()+"\n")
+		}
+	}
+}
+
+//execute runs prog, with limits that start afresh for every program
+func (i *Interpreter) execute(prog *ast.Program) object.Object {
+	budget := object.NewBudget(i.opts.Limits)
+
+	if i.opts.Engine == EngineVM {
+		c := compiler.NewWithState(i.symbols, i.constants)
+		c.SetBuiltins(i.builtins)
+		if err := c.Compile(prog); err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		bytecode := c.Bytecode()
+		i.constants = bytecode.Constants
+
+		machine := vm.NewWithGlobals(bytecode, i.globals)
+		machine.SetBudget(budget)
+		return machine.Run()
+	}
+
+	i.env.SetBudget(budget)
+	return evaluator.Eval(prog, i.env)
+}
+
+func Interpret(in string) {
+	InterpretWith(in, DefaultOptions())
+}
+
+//InterpretWith runs in with the given options and prints its result or errors to opts.Stdout
+func InterpretWith(in string, opts Options) {
+	New(opts).Interpret(in)
+}
+
+//RunFile runs the script at filename with args bound to the 'args' array, returning a process exit code
+func RunFile(filename string, args []string, opts Options) int {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(opts.withDefaults().Stderr, "plz: %s\n", err)
+		return ExitError
+	}
+
+	return Run(filename, string(src), args, opts)
+}
+
+//Run runs src as a script. Diagnostics go to opts.Stderr; the script's own output comes from print
+func Run(filename string, src string, args []string, opts Options) int {
+	globals := map[string]object.Object{"args": argsArray(args)}
+	for name, value := range opts.Globals {
+		globals[name] = value
+	}
+	opts.Globals = globals
+
+	i := New(opts)
+	_, err := i.run(filename, stripShebang(src))
+
+	switch err := err.(type) {
+	case *SyntaxError:
+		for _, e := range err.Errors {
+			fmt.Fprintln(i.opts.Stderr, e)
 		}
+		return ExitSyntaxError
+	case *RuntimeError:
+		fmt.Fprintln(i.opts.Stderr, err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+//stripShebang blanks out a leading '#!' line, keeping the newline so line numbers stay correct
+func stripShebang(src string) string {
+	if !strings.HasPrefix(src, "#!") {
+		return src
+	}
+	if i := strings.IndexByte(src, '\n'); i >= 0 {
+		return src[i:]
+	}
+	return ""
+}
+
+//copyValue copies arrays and hashes and what they hold, keeping cycles and values shared
+//within value as they are. copies maps the containers copied so far to their copies
+func copyValue(value object.Object, copies map[object.Object]object.Object) object.Object {
+	if copied, ok := copies[value]; ok {
+		return copied
 	}
 
-	evaluated := evaluator.Eval(prog, env)
-	if evaluated != nil {
-		out += evaluated.Inspect() + "\n"
+	switch value := value.(type) {
+	case *object.HashObject:
+		return copyValue(value.Inner, copies)
+	case *object.Array:
+		array := &object.Array{Elements: make([]object.Object, len(value.Elements))}
+		copies[value] = array
+		for i, element := range value.Elements {
+			array.Elements[i] = copyValue(element, copies)
+		}
+		return array
+	case *object.HashMap:
+		hash := object.NewHashMap()
+		copies[value] = hash
+		for _, pair := range value.Items() {
+			hash.Set(pair.Key.(object.Hashable), copyValue(pair.Value, copies))
+		}
+		return hash
+	default:
+		return value //shared as they are; programs can't change numbers, strings or builtins
 	}
+}
 
-	fmt.Print(out)
+func argsArray(args []string) *object.Array {
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+	return &object.Array{Elements: elements}
 }
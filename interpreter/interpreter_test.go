@@ -0,0 +1,396 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MYKatz/PLZ/object"
+)
+
+func TestRunExitCodes(t *testing.T) {
+	tests := []struct {
+		input    string
+		args     []string
+		expected int
+	}{
+		{"let x be 1 plz", nil, ExitOK},
+		{"#!/usr/bin/env plz run\nlet x be 1 plz", nil, ExitOK},
+		{"let x be plz", nil, ExitSyntaxError},
+		{"let x be 1 + True plz", nil, ExitError},
+		{`if (len(args[1]) == 1) please 1 thanks else please 1 + True thanks`, []string{"a", "b"}, ExitOK},
+		{`if (len(args) == 0) please 1 + True thanks`, nil, ExitError},
+	}
+
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		for _, tt := range tests {
+			code := Run("test.plz", tt.input, tt.args, Options{Engine: engine, Limits: object.DefaultLimits(), Stderr: ioutil.Discard})
+			if code != tt.expected {
+				t.Errorf("Incorrect exit code for %q with engine %s. Expected %d, received %d", tt.input, engine, tt.expected, code)
+			}
+		}
+	}
+}
+
+func TestStripShebang(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"#!/usr/bin/env plz run\nprint(1)", "\nprint(1)"},
+		{"#!plz", ""},
+		{"print(1)", "print(1)"},
+	}
+
+	for _, tt := range tests {
+		if out := stripShebang(tt.input); out != tt.expected {
+			t.Errorf("stripShebang(%q) incorrect. Expected %q, received %q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-expired.Done()
+
+	tests := []struct {
+		input    string
+		limits   object.Limits
+		expected string
+	}{
+		{"let f be function() please return f() plz thanks plz f()", object.Limits{MaxDepth: 100}, "maximum call depth exceeded: more than 100 nested calls"},
+		{"let f be function() please return f() plz thanks plz f()", object.DefaultLimits(), "maximum call depth exceeded: more than 10000 nested calls"},
+		{"let f be function() please return f() plz thanks plz try please f() thanks sorry please 0 thanks", object.Limits{MaxDepth: 100}, "maximum call depth exceeded: more than 100 nested calls"},
+		{"while (True) please 1 thanks", object.Limits{MaxSteps: 1000}, "step limit exceeded: more than 1000 steps"},
+		{`let s be "ab" plz while (True) please s = s + s plz thanks`, object.Limits{MaxAllocation: 1000}, "allocation limit exceeded: size 1024 is over the limit of 1000"},
+		{"let a be [] plz while (True) please a = append(a, 1) plz thanks", object.Limits{MaxAllocation: 10}, "allocation limit exceeded: size 11 is over the limit of 10"},
+		{"while (True) please 1 thanks", object.Limits{Context: expired}, "time limit exceeded"},
+	}
+
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		for _, tt := range tests {
+			_, err := New(Options{Engine: engine, Limits: tt.limits}).Run(tt.input)
+			runtimeErr, ok := err.(*RuntimeError)
+			if !ok {
+				t.Errorf("no runtime error returned for %q with engine %s. Received %v", tt.input, engine, err)
+				continue
+			}
+			if runtimeErr.Err.Message != tt.expected {
+				t.Errorf("wrong error message with engine %s. Expected %q, received %q", engine, tt.expected, runtimeErr.Err.Message)
+			}
+		}
+	}
+}
+
+func TestInterpreterRun(t *testing.T) {
+	tests := []struct {
+		inputs  []string //run one after another by the same interpreter
+		stdin   string
+		result  string
+		stdout  string
+		globals map[string]object.Object
+	}{
+		{[]string{"1 + 2"}, "", "3", "", nil},
+		{[]string{`print("hello") plz print(1, [2]) plz 5`}, "", "5", "hello\n1\n[2]\n", nil},
+		{[]string{"let x be 2 plz", "let double be function(n) please n * 2 thanks plz", "double(x)"}, "", "4", "", nil},
+		{[]string{"let x be 1 plz"}, "", "<nil>", "", nil},
+		{[]string{`name + "!"`}, "", "Matt!", "", map[string]object.Object{"name": &object.String{Value: "Matt"}}},
+		{[]string{`let h be {"a": 1} plz h["a"]`}, "", "1", "", nil},
+		{[]string{`let name be input("name? ") plz "hi " + name`}, "Matt\n", "hi Matt", "name? ", nil},
+		{[]string{`[input(), input(), input()]`}, "a\r\nb", "[a, b, Null]", "", nil},
+	}
+
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		for _, tt := range tests {
+			var stdout bytes.Buffer
+			i := New(Options{Engine: engine, Stdout: &stdout, Stdin: strings.NewReader(tt.stdin), Globals: tt.globals})
+
+			var result object.Object
+			for _, input := range tt.inputs {
+				var err error
+				result, err = i.Run(input)
+				if err != nil {
+					t.Fatalf("error running %q with engine %s: %s", input, engine, err)
+				}
+			}
+
+			if describe(result) != tt.result {
+				t.Errorf("Incorrect result for %q with engine %s. Expected %s, received %s", tt.inputs, engine, tt.result, describe(result))
+			}
+			if stdout.String() != tt.stdout {
+				t.Errorf("Incorrect output for %q with engine %s. Expected %q, received %q", tt.inputs, engine, tt.stdout, stdout.String())
+			}
+		}
+	}
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		i := New(Options{Engine: engine, Stdout: ioutil.Discard})
+
+		_, err := i.Run("let x be plz")
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("Expected a SyntaxError with engine %s, received %T", engine, err)
+		}
+		if len(syntaxErr.Errors) != 1 || syntaxErr.Errors[0] != "1:10: expected an expression, found 'plz'" {
+			t.Errorf("Incorrect syntax errors with engine %s: %q", engine, syntaxErr.Errors)
+		}
+
+		_, err = i.Run(`complain("oops", "ValueError")`)
+		runtimeErr, ok := err.(*RuntimeError)
+		if !ok {
+			t.Fatalf("Expected a RuntimeError with engine %s, received %T", engine, err)
+		}
+		if runtimeErr.Err.ErrorType() != "ValueError" || runtimeErr.Err.Message != "oops" {
+			t.Errorf("Incorrect runtime error with engine %s: %s", engine, runtimeErr.Err.Inspect())
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	greet := func(args ...object.Object) object.Object {
+		if err := object.CheckArgs("greet", args, object.STRING_OBJ); err != nil {
+			return err
+		}
+		return &object.String{Value: "Hello, " + args[0].(*object.String).Value}
+	}
+	max := func(args ...object.Object) object.Object {
+		if err := object.CheckArgCount(args, 1, -1); err != nil {
+			return err
+		}
+		if err := object.CheckArgTypes("max", args, object.INTEGER_OBJ, object.INTEGER_OBJ, object.INTEGER_OBJ); err != nil {
+			return err
+		}
+		best := args[0].(*object.Integer)
+		for _, arg := range args {
+			if n := arg.(*object.Integer); n.Value > best.Value {
+				best = n
+			}
+		}
+		return best
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`greet("Matt")`, "Hello, Matt"},
+		{`let h be {"name": "Jeff"} plz greet(h.name)`, "Hello, Jeff"},
+		{`greet(1)`, "Invalid argument to greet. Expected string, received INTEGER"},
+		{`greet()`, "Incorrect number of arguments. Expected 1, received 0"},
+		{`numbers.max(3, 9, 4)`, "9"},
+		{`let m be numbers.max plz m(1)`, "1"},
+		{`numbers.max(1, "2")`, "Invalid second argument to max. Expected integer, received STRING"},
+		{`numbers.max()`, "Incorrect number of arguments. Expected at least 1, received 0"},
+		{`let greet be 1 plz`, "Invalid let statement: cannot override builtin function greet"},
+	}
+
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		i := New(Options{Engine: engine})
+		i.Register("greet", greet)
+		i.RegisterModule("numbers", map[string]object.BuiltinFunction{"max": max})
+
+		for _, tt := range tests {
+			result, err := i.Run(tt.input)
+			if runtimeErr, ok := err.(*RuntimeError); ok {
+				if runtimeErr.Err.Message != tt.expected {
+					t.Errorf("Incorrect error for %q with engine %s. Expected %q, received %q", tt.input, engine, tt.expected, runtimeErr.Err.Message)
+				}
+				continue
+			} else if err != nil {
+				t.Fatalf("error running %q with engine %s: %s", tt.input, engine, err)
+			}
+			if describe(result) != tt.expected {
+				t.Errorf("Incorrect result for %q with engine %s. Expected %s, received %s", tt.input, engine, tt.expected, describe(result))
+			}
+		}
+
+		//builtins belong to the interpreter they were registered with
+		if _, err := New(Options{Engine: engine}).Run(`greet("Matt")`); err == nil {
+			t.Errorf("builtin registered with one interpreter visible from another with engine %s", engine)
+		}
+	}
+}
+
+func TestGoValues(t *testing.T) {
+	type user struct {
+		Name string `plz:"name"`
+		Age  int    `plz:"age"`
+	}
+	older := func(u user, years int) user {
+		u.Age += years
+		return u
+	}
+
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		i := New(Options{Engine: engine})
+		for name, value := range map[string]interface{}{"jeff": user{Name: "Jeff", Age: 40}, "older": older} {
+			obj, err := object.FromGo(value)
+			if err != nil {
+				t.Fatalf("error converting %s: %s", name, err)
+			}
+			i.Set(name, obj)
+		}
+
+		result, err := i.Run(`older(jeff, 2)`)
+		if err != nil {
+			t.Fatalf("error running with engine %s: %s", engine, err)
+		}
+		var u user
+		if err := object.ToGo(result, &u); err != nil {
+			t.Fatalf("error converting result with engine %s: %s", engine, err)
+		}
+		if u != (user{Name: "Jeff", Age: 42}) {
+			t.Errorf("Incorrect result with engine %s: %+v", engine, u)
+		}
+
+		if _, err := i.Run(`older(jeff, "two")`); err == nil {
+			t.Errorf("no error passing a string as an int with engine %s", engine)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	script := `
+let greet be function(name, greeting be "Hello") please
+	return greeting + ", " + name plz
+thanks plz
+let count be 0 plz
+on("click", function() please count += 1 plz return count plz thanks) plz
+on("loop", function() please while (True) please 1 thanks thanks) plz
+let fail be function() please complain("handler failed") plz thanks plz
+let notAFunction be 1 plz
+`
+
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		i := New(Options{Engine: engine, Limits: object.Limits{MaxSteps: 1000}})
+		handlers := map[string]object.Object{}
+		i.Register("on", func(args ...object.Object) object.Object {
+			handlers[args[0].(*object.String).Value] = args[1]
+			return &object.Null{}
+		})
+		if _, err := i.Run(script); err != nil {
+			t.Fatalf("error running script with engine %s: %s", engine, err)
+		}
+
+		tests := []struct {
+			call     func() (object.Object, error)
+			expected string
+		}{
+			{func() (object.Object, error) { return i.Call("greet", &object.String{Value: "Matt"}) }, "Hello, Matt"},
+			{func() (object.Object, error) {
+				return i.Call("greet", &object.String{Value: "Jeff"}, &object.String{Value: "Hi"})
+			}, "Hi, Jeff"},
+			{func() (object.Object, error) { return i.Call("greet") }, "wrong number of arguments: expected 1 to 2, received 0"},
+			{func() (object.Object, error) { return i.Call("missing") }, "identifier not found: missing"},
+			{func() (object.Object, error) { return i.Call("notAFunction") }, "not a function: INTEGER"},
+			{func() (object.Object, error) { return i.Call("fail") }, "handler failed"},
+			{func() (object.Object, error) { return i.Call("len", &object.String{Value: "abc"}) }, "identifier not found: len"},
+			{func() (object.Object, error) { return i.CallFunction(handlers["click"]) }, "1"},
+			{func() (object.Object, error) { return i.CallFunction(handlers["click"]) }, "2"},
+			{func() (object.Object, error) { return i.CallFunction(handlers["loop"]) }, "step limit exceeded: more than 1000 steps"},
+			{func() (object.Object, error) { return i.Run("count") }, "2"},
+		}
+
+		for n, tt := range tests {
+			result, err := tt.call()
+			if runtimeErr, ok := err.(*RuntimeError); ok {
+				if runtimeErr.Err.Message != tt.expected {
+					t.Errorf("Test #%d: incorrect error with engine %s. Expected %q, received %q", n, engine, tt.expected, runtimeErr.Err.Message)
+				}
+				continue
+			} else if err != nil {
+				t.Fatalf("Test #%d: unexpected error with engine %s: %s", n, engine, err)
+			}
+			if describe(result) != tt.expected {
+				t.Errorf("Test #%d: incorrect result with engine %s. Expected %s, received %s", n, engine, tt.expected, describe(result))
+			}
+		}
+
+		//the host isn't part of the program, so the traceback starts at the function it called
+		_, err := i.Call("fail")
+		expected := "Traceback (most recent call last):\n  8:31, in fail\nError: handler failed"
+		if err == nil || err.Error() != expected {
+			t.Errorf("incorrect traceback with engine %s. Expected %q, received %v", engine, expected, err)
+		}
+	}
+}
+
+func TestConcurrentInterpreters(t *testing.T) {
+	//run with -race: interpreters given the same values must not share anything they change
+	shared := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}}
+	script := `
+let total be 0 plz
+for n in numbers please total += n plz thanks
+assign(numbers, 0, id) plz
+numbers[1] be id * 2 plz
+let squares be [] plz
+let i be 0 plz
+while (i < 20) please
+	let squares be append(squares, i * i) plz
+	i += 1 plz
+thanks
+let caught be try please complain("oops") thanks sorry (e) please e.message thanks plz
+print(id, total, rest(numbers), first(squares) == 0, caught, !False) plz
+numbers[0] + numbers[1]
+`
+
+	const runs = 200
+	errs := make(chan error, runs)
+	var wg sync.WaitGroup
+	for n := 0; n < runs; n++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+
+			engine := EngineEval
+			if id%2 == 1 {
+				engine = EngineVM
+			}
+			var out bytes.Buffer
+			i := New(Options{
+				Engine:  engine,
+				Limits:  object.Limits{MaxSteps: 100000},
+				Stdout:  &out,
+				Globals: map[string]object.Object{"numbers": shared, "id": &object.Integer{Value: id}},
+			})
+
+			result, err := i.Run(script)
+			if err != nil {
+				errs <- fmt.Errorf("run %d: %s", id, err)
+				return
+			}
+			expected := fmt.Sprintf("%d\n3\n[%d]\ntrue\noops\ntrue\n", id, id*2)
+			if out.String() != expected {
+				errs <- fmt.Errorf("run %d: incorrect output %q, expected %q", id, out.String(), expected)
+			}
+			if describe(result) != fmt.Sprint(id*3) {
+				errs <- fmt.Errorf("run %d: incorrect result %s", id, describe(result))
+			}
+		}(int64(n))
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if shared.Inspect() != "[1, 2]" {
+		t.Errorf("programs changed the host's array: %s", shared.Inspect())
+	}
+}
+
+//describe shows a result, telling no value apart from Null
+func describe(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return obj.Inspect()
+}
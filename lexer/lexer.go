@@ -6,18 +6,40 @@ import (
 
 type Lexer struct {
 	input        string
+	filename     string
 	position     int  //index of current character
 	readPosition int  //current reading position -> next char to read
 	ch           byte //current char to evaluate
+	line         int  //line of current character, 1-based
+	column       int  //column of current character, 1-based
+	keepComments bool //emit COMMENT tokens instead of skipping comments
 }
 
 func NewLexer(inp string) *Lexer {
-	l := &Lexer{input: inp}
+	return NewFileLexer("", inp)
+}
+
+//NewFileLexer creates a lexer whose token positions report the given filename
+func NewFileLexer(filename string, inp string) *Lexer {
+	l := &Lexer{input: inp, filename: filename, line: 1}
 	l.readChar()
 	return l
 }
 
+//KeepComments makes the lexer return comments as COMMENT tokens, for tools such as formatters
+//that need them. The parser expects them to be skipped, which is the default
+func (l *Lexer) KeepComments(keep bool) {
+	l.keepComments = keep
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 1
+	} else {
+		l.column += 1
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 //sets character to null value
 	} else {
@@ -27,6 +49,14 @@ func (l *Lexer) readChar() {
 	l.readPosition += 1
 }
 
+//peekChar returns the character n places after the current one
+func (l *Lexer) peekChar(n int) byte {
+	if l.position+n >= len(l.input) {
+		return 0
+	}
+	return l.input[l.position+n]
+}
+
 func (l *Lexer) checkChar() byte {
 	if l.readPosition >= len(l.input) {
 		return 0
@@ -35,9 +65,27 @@ func (l *Lexer) checkChar() byte {
 	}
 }
 
+//currentPosition returns the source position of the current character
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{Filename: l.filename, Line: l.line, Column: l.column}
+}
+
 func (l *Lexer) NextToken() token.Token {
 	l.eatWhitespace()
+	for l.ch == '/' && (l.checkChar() == '/' || l.checkChar() == '*') {
+		start := l.currentPosition()
+		comment, ok := l.readComment()
+		if !ok {
+			return token.Token{Type: token.ILLEGAL, Literal: "/*", Pos: start, End: l.currentPosition()} //unterminated block comment
+		}
+		if l.keepComments {
+			return token.Token{Type: token.COMMENT, Literal: comment, Pos: start, End: l.currentPosition()}
+		}
+		l.eatWhitespace()
+	}
+
 	var tok token.Token
+	start := l.currentPosition()
 	switch l.ch {
 	case '(':
 		tok = token.NewToken(token.LPAREN, l.ch)
@@ -50,9 +98,15 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = token.NewToken(token.COMMA, l.ch)
 	case '+':
-		tok = token.NewToken(token.PLUS, l.ch)
+		tok = l.operatorToken(token.PLUS, token.PLUS_ASSIGN)
 	case '.':
-		tok = token.NewToken(token.PERIOD, l.ch)
+		if l.checkChar() == '.' && l.peekChar(2) == '.' {
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+			l.readChar()
+			l.readChar()
+		} else {
+			tok = token.NewToken(token.PERIOD, l.ch)
+		}
 	case '!':
 		if l.checkChar() == '=' {
 			tok = token.Token{Type: token.NOT_EQ, Literal: "!="}
@@ -68,11 +122,11 @@ func (l *Lexer) NextToken() token.Token {
 			tok = token.NewToken(token.ASSIGN, l.ch) //this is what allows declarations to optionally use '='
 		}
 	case '*':
-		tok = token.NewToken(token.ASTERISK, l.ch)
+		tok = l.operatorToken(token.ASTERISK, token.ASTERISK_ASSIGN)
 	case '-':
-		tok = token.NewToken(token.MINUS, l.ch)
+		tok = l.operatorToken(token.MINUS, token.MINUS_ASSIGN)
 	case '/':
-		tok = token.NewToken(token.SLASH, l.ch)
+		tok = l.operatorToken(token.SLASH, token.SLASH_ASSIGN)
 	case '<':
 		tok = token.NewToken(token.LT, l.ch)
 	case '>':
@@ -93,10 +147,11 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos, tok.End = start, l.currentPosition()
 			return tok //early exit
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNum()
+			tok.Literal, tok.Type = l.readNum()
+			tok.Pos, tok.End = start, l.currentPosition()
 			return tok
 		} else {
 			tok = token.NewToken(token.ILLEGAL, l.ch)
@@ -104,9 +159,20 @@ func (l *Lexer) NextToken() token.Token {
 	}
 
 	l.readChar()
+	tok.Pos, tok.End = start, l.currentPosition()
 	return tok
 }
 
+//operatorToken lexes an arithmetic operator, or its compound assignment form when followed by '='
+func (l *Lexer) operatorToken(plain token.TokenType, compound token.TokenType) token.Token {
+	if l.checkChar() == '=' {
+		ch := l.ch
+		l.readChar()
+		return token.Token{Type: compound, Literal: string(ch) + "="}
+	}
+	return token.NewToken(plain, l.ch)
+}
+
 func (l *Lexer) readIdentifier() string {
 	pos := l.position    //start position
 	for isLetter(l.ch) { //while loop
@@ -115,12 +181,37 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[pos:l.position] //slice input from start of current token to 'next' one
 }
 
-func (l *Lexer) readNum() string {
+//readNum reads an integer, or a float if it has a fractional part or exponent (1.5, 2e10, 3.1e-2)
+func (l *Lexer) readNum() (string, token.TokenType) {
 	pos := l.position
+	tt := token.TokenType(token.INT)
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[pos:l.position]
+
+	if l.ch == '.' && isDigit(l.checkChar()) {
+		tt = token.FLOAT
+		l.readChar()
+		for isDigit(l.ch) {
+			l.readChar()
+		}
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar(1)
+		if isDigit(next) || ((next == '+' || next == '-') && isDigit(l.peekChar(2))) {
+			tt = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			for isDigit(l.ch) {
+				l.readChar()
+			}
+		}
+	}
+
+	return l.input[pos:l.position], tt
 }
 
 func isLetter(ch byte) bool {
@@ -137,6 +228,37 @@ func (l *Lexer) eatWhitespace() {
 	}
 }
 
+//readComment reads a '//' comment up to the end of the line, or a '/* */' comment which may nest.
+//It returns false if a block comment is never closed
+func (l *Lexer) readComment() (string, bool) {
+	pos := l.position
+	if l.checkChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		return l.input[pos:l.position], true
+	}
+
+	depth := 0
+	for {
+		switch {
+		case l.ch == 0:
+			return l.input[pos:l.position], false
+		case l.ch == '/' && l.checkChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.checkChar() == '/':
+			depth--
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return l.input[pos:l.position], true
+			}
+		}
+		l.readChar()
+	}
+}
+
 func (l *Lexer) readString() string {
 	pos := l.position + 1
 	for {
@@ -15,7 +15,7 @@ func TestNextToken(test *testing.T) {
 	thanks
 	
 	let result be add(five, ten) plz
-	!-/*5 plz
+	!-/ *5 plz
 	5 < 10 > 5 plz
 
 	if (5 < 10) please
@@ -141,3 +141,209 @@ func TestNextToken(test *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(test *testing.T) {
+	input := "let x be 5 plz\n  x + \"hi\""
+
+	tests := []struct {
+		expectedLiteral string
+		line            int
+		column          int
+		endColumn       int
+	}{
+		{"let", 1, 1, 4},
+		{"x", 1, 5, 6},
+		{"be", 1, 7, 9},
+		{"5", 1, 10, 11},
+		{"plz", 1, 12, 15},
+		{"x", 2, 3, 4},
+		{"+", 2, 5, 6},
+		{"hi", 2, 7, 11},
+	}
+
+	l := NewFileLexer("script.plz", input)
+	for i, exp := range tests {
+		t := l.NextToken()
+
+		if t.Literal != exp.expectedLiteral {
+			test.Fatalf("Test #%d: incorrect token literal. Expected %s, received %s", i, exp.expectedLiteral, t.Literal)
+		}
+
+		if t.Pos.Line != exp.line || t.Pos.Column != exp.column {
+			test.Errorf("Test #%d: incorrect position. Expected %d:%d, received %d:%d", i, exp.line, exp.column, t.Pos.Line, t.Pos.Column)
+		}
+
+		if t.End.Line != exp.line || t.End.Column != exp.endColumn {
+			test.Errorf("Test #%d: incorrect end position. Expected %d:%d, received %d:%d", i, exp.line, exp.endColumn, t.End.Line, t.End.Column)
+		}
+
+		if t.Pos.Filename != "script.plz" {
+			test.Errorf("Test #%d: incorrect filename. Expected script.plz, received %q", i, t.Pos.Filename)
+		}
+	}
+}
+
+func TestComments(test *testing.T) {
+	input := `let a be 1 plz // the first one
+	/* a block
+	   comment */ let b be /* inline */ 2 plz
+	/* outer /* nested */ still outer */ a / b //trailing`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "a"},
+		{token.ASSIGN, "be"},
+		{token.INT, "1"},
+		{token.TERMINATOR, "plz"},
+		{token.LET, "let"},
+		{token.IDENT, "b"},
+		{token.ASSIGN, "be"},
+		{token.INT, "2"},
+		{token.TERMINATOR, "plz"},
+		{token.IDENT, "a"},
+		{token.SLASH, "/"},
+		{token.IDENT, "b"},
+		{token.EOF, ""},
+	}
+
+	l := NewLexer(input)
+	for i, exp := range tests {
+		t := l.NextToken()
+
+		if t.Type != exp.expectedType {
+			test.Fatalf("Test #%d: incorrect token type. Expected %s, received %s.", i, exp.expectedType, t.Type)
+		}
+
+		if t.Literal != exp.expectedLiteral {
+			test.Fatalf("Test #%d: incorrect token literal. Expected %s, received %s", i, exp.expectedLiteral, t.Literal)
+		}
+	}
+}
+
+func TestKeepComments(test *testing.T) {
+	input := "// header\nlet a be /* one */ 1 plz"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// header"},
+		{token.LET, "let"},
+		{token.IDENT, "a"},
+		{token.ASSIGN, "be"},
+		{token.COMMENT, "/* one */"},
+		{token.INT, "1"},
+		{token.TERMINATOR, "plz"},
+		{token.EOF, ""},
+	}
+
+	l := NewLexer(input)
+	l.KeepComments(true)
+	for i, exp := range tests {
+		t := l.NextToken()
+
+		if t.Type != exp.expectedType {
+			test.Fatalf("Test #%d: incorrect token type. Expected %s, received %s.", i, exp.expectedType, t.Type)
+		}
+
+		if t.Literal != exp.expectedLiteral {
+			test.Fatalf("Test #%d: incorrect token literal. Expected %s, received %s", i, exp.expectedLiteral, t.Literal)
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(test *testing.T) {
+	l := NewLexer("1 /* never /* closed */")
+	l.NextToken()
+
+	t := l.NextToken()
+	if t.Type != token.ILLEGAL || t.Literal != "/*" {
+		test.Fatalf("Incorrect token. Expected ILLEGAL /*, received %s %s", t.Type, t.Literal)
+	}
+	if t.Pos.Column != 3 {
+		test.Errorf("Incorrect position. Expected column 3, received %d", t.Pos.Column)
+	}
+}
+
+func TestNumbers(test *testing.T) {
+	input := `5 3.14 1e10 2.5E-3 7e+2 4.x 6e a.b ...c ..`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e10"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "7e+2"},
+		{token.INT, "4"},
+		{token.PERIOD, "."},
+		{token.IDENT, "x"},
+		{token.INT, "6"},
+		{token.IDENT, "e"},
+		{token.IDENT, "a"},
+		{token.PERIOD, "."},
+		{token.IDENT, "b"},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "c"},
+		{token.PERIOD, "."},
+		{token.PERIOD, "."},
+		{token.EOF, ""},
+	}
+
+	l := NewLexer(input)
+	for i, exp := range tests {
+		t := l.NextToken()
+
+		if t.Type != exp.expectedType {
+			test.Fatalf("Test #%d: incorrect token type. Expected %s, received %s.", i, exp.expectedType, t.Type)
+		}
+
+		if t.Literal != exp.expectedLiteral {
+			test.Fatalf("Test #%d: incorrect token literal. Expected %s, received %s", i, exp.expectedLiteral, t.Literal)
+		}
+	}
+}
+
+func TestCompoundAssignment(test *testing.T) {
+	input := `x += 1 y -= 2 z *= 3 w /= 4 a = b`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.IDENT, "y"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.IDENT, "z"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.IDENT, "w"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "4"},
+		{token.IDENT, "a"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "b"},
+		{token.EOF, ""},
+	}
+
+	l := NewLexer(input)
+	for i, exp := range tests {
+		t := l.NextToken()
+
+		if t.Type != exp.expectedType {
+			test.Fatalf("Test #%d: incorrect token type. Expected %s, received %s.", i, exp.expectedType, t.Type)
+		}
+
+		if t.Literal != exp.expectedLiteral {
+			test.Fatalf("Test #%d: incorrect token literal. Expected %s, received %s", i, exp.expectedLiteral, t.Literal)
+		}
+	}
+}
@@ -1,26 +1,124 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"log"
+	"net/http"
 	"os"
 	"os/user"
+	"strings"
 
 	"github.com/MYKatz/PLZ/interpreter"
+	"github.com/MYKatz/PLZ/object"
 	"github.com/MYKatz/PLZ/repl"
+	"github.com/MYKatz/PLZ/server"
 )
 
 func main() {
 	code := flag.String("code", "", "code string to run")
+	engine := flag.String("engine", string(interpreter.EngineEval), "execution engine: eval (tree-walking) or vm (bytecode)")
+	defaults := object.DefaultLimits()
+	maxSteps := flag.Int64("max-steps", defaults.MaxSteps, "maximum evaluation steps, 0 for no limit")
+	maxDepth := flag.Int("max-depth", defaults.MaxDepth, "maximum nested function calls, 0 for no limit")
+	maxAlloc := flag.Int("max-alloc", defaults.MaxAllocation, "maximum length of a string, array or hash, 0 for no limit")
+	timeout := flag.Duration("timeout", 0, "stop the program after this long, e.g. 5s; 0 for no limit")
+	flag.Usage = usage
 	flag.Parse()
 
+	if *engine != string(interpreter.EngineEval) && *engine != string(interpreter.EngineVM) {
+		fmt.Fprintf(os.Stderr, "plz: unknown engine %q\n", *engine)
+		usage()
+		os.Exit(interpreter.ExitError)
+	}
+
+	opts := interpreter.Options{
+		Engine: interpreter.Engine(*engine),
+		Limits: object.Limits{MaxSteps: *maxSteps, MaxDepth: *maxDepth, MaxAllocation: *maxAlloc},
+	}
+	if *timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		opts.Limits.Context = ctx
+	}
+
 	if *code != "" {
-		interpreter.Interpret(*code)
+		interpreter.InterpretWith(*code, opts)
+	} else if flag.Arg(0) == "run" {
+		if flag.NArg() < 2 {
+			usage()
+			os.Exit(interpreter.ExitError)
+		}
+		os.Exit(interpreter.RunFile(flag.Arg(1), flag.Args()[2:], opts))
+	} else if flag.Arg(0) == "serve" {
+		serve(flag.Args()[1:], opts.Engine)
 	} else {
+		if set := runFlagsSet(); len(set) > 0 {
+			verb := "apply"
+			if len(set) == 1 {
+				verb = "applies"
+			}
+			fmt.Fprintf(os.Stderr, "plz: %s only %s to -code and run; the REPL always uses the tree-walking evaluator with default limits\n", strings.Join(set, ", "), verb)
+			os.Exit(interpreter.ExitError)
+		}
 		openrepl()
 	}
 }
 
+//runFlagsSet lists the flags given that only change how -code and run execute programs
+func runFlagsSet() []string {
+	set := []string{}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "engine":
+			if f.Value.String() != string(interpreter.EngineEval) {
+				set = append(set, "-"+f.Name)
+			}
+		case "max-steps", "max-depth", "max-alloc", "timeout":
+			set = append(set, "-"+f.Name)
+		}
+	})
+	return set
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  plz                          start the REPL, which always uses eval and default limits\n")
+	fmt.Fprintf(os.Stderr, "  plz -code \"...\"              run a code string\n")
+	fmt.Fprintf(os.Stderr, "  plz run file.plz [args...]   run a script\n")
+	fmt.Fprintf(os.Stderr, "  plz -engine vm run file.plz  run a script on the bytecode virtual machine\n")
+	fmt.Fprintf(os.Stderr, "  plz serve [-addr :8080]      serve the playground over HTTP\n")
+	flag.PrintDefaults()
+}
+
+//serve runs the playground server, which sandboxes every program with limits of its own
+//rather than the ones given on the command line
+func serve(args []string, engine interpreter.Engine) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	static := flags.String("static", "playground", "directory holding index.html, examples.js and favicon.png")
+	snippets := flags.String("snippets", "", "directory to save shared snippets in; if empty they are kept in memory, up to\n-max-snippets and -max-snippet-bytes with the oldest dropped first")
+	maxSnippets := flags.Int("max-snippets", 10000, "most snippets kept in memory, 0 for no limit")
+	maxSnippetBytes := flags.Int("max-snippet-bytes", 64<<20, "most bytes of code kept in memory across all snippets, 0 for no limit")
+	flags.Parse(args)
+
+	opts := server.DefaultOptions()
+	opts.Engine = engine
+	opts.Static = http.Dir(*static)
+	opts.Snippets = server.NewMemoryStore(*maxSnippets, *maxSnippetBytes)
+	if *snippets != "" {
+		store, err := server.NewFileStore(*snippets)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Snippets = store
+	}
+
+	log.Printf("serving the PLZ playground on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, server.New(opts)))
+}
+
 func openrepl() {
 	user, err := user.Current()
 	if err != nil {
@@ -0,0 +1,67 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+//ANY matches an argument of any type in CheckArgTypes
+const ANY = "ANY"
+
+//CheckArgCount returns an error unless there are at least min and at most max arguments.
+//A negative max allows any number past min
+func CheckArgCount(args []Object, min int, max int) *Error {
+	if len(args) >= min && (max < 0 || len(args) <= max) {
+		return nil
+	}
+
+	var expected string
+	switch {
+	case max < 0:
+		expected = fmt.Sprintf("at least %d", min)
+	case min == max:
+		expected = fmt.Sprintf("%d", min)
+	case min+1 == max:
+		expected = fmt.Sprintf("%d or %d", min, max)
+	default:
+		expected = fmt.Sprintf("%d to %d", min, max)
+	}
+	return &Error{Message: fmt.Sprintf("Incorrect number of arguments. Expected %s, received %d", expected, len(args))}
+}
+
+//CheckArgTypes returns an error if an argument to the builtin called name isn't of the type
+//given for its position. Arguments past the end of types aren't checked
+func CheckArgTypes(name string, args []Object, types ...string) *Error {
+	for i, arg := range args {
+		if i >= len(types) || types[i] == ANY || arg.Type() == types[i] {
+			continue
+		}
+
+		expected := strings.ToLower(types[i])
+		if len(types) == 1 {
+			return &Error{Message: fmt.Sprintf("Invalid argument to %s. Expected %s, received %s", name, expected, arg.Type())}
+		}
+		return &Error{Message: fmt.Sprintf("Invalid %s argument to %s. Expected %s, received %s", ordinal(i+1), name, expected, arg.Type())}
+	}
+	return nil
+}
+
+//CheckArgs checks there is exactly one argument of each of the given types
+func CheckArgs(name string, args []Object, types ...string) *Error {
+	if err := CheckArgCount(args, len(types), len(types)); err != nil {
+		return err
+	}
+	return CheckArgTypes(name, args, types...)
+}
+
+func ordinal(n int) string {
+	switch n {
+	case 1:
+		return "first"
+	case 2:
+		return "second"
+	case 3:
+		return "third"
+	}
+	return fmt.Sprintf("%dth", n)
+}
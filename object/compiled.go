@@ -0,0 +1,83 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/MYKatz/PLZ/code"
+	"github.com/MYKatz/PLZ/token"
+)
+
+const (
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CELL_OBJ              = "CELL"
+)
+
+//compiled function, produced by the compiler package and run by the vm package
+
+type CompiledFunction struct {
+	Name          string
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int              //named parameters, including those with defaults
+	NumRequired   int              //parameters that must be passed
+	Variadic      bool             //extra arguments are collected into an array in the local after the parameters
+	LocalNames    []string         //names of the local slots, for error messages
+	FreeNames     []string         //names of the captured variables, for error messages
+	Positions     []InstructionPos //sorted by offset
+}
+
+//InstructionPos records the source position of the instructions starting at Offset
+type InstructionPos struct {
+	Offset int
+	Pos    token.Position
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+func (cf *CompiledFunction) Type() string {
+	return COMPILED_FUNCTION_OBJ
+}
+
+//PositionAt returns the source position of the instruction at offset ip
+func (cf *CompiledFunction) PositionAt(ip int) token.Position {
+	i := sort.Search(len(cf.Positions), func(i int) bool { return cf.Positions[i].Offset > ip })
+	if i == 0 {
+		return token.Position{}
+	}
+	return cf.Positions[i-1].Pos
+}
+
+//closure
+
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Cell
+}
+
+func (c *Closure) Inspect() string {
+	return "" //matches Function, so both engines print the same
+}
+
+func (c *Closure) Type() string {
+	return FUNCTION_OBJ
+}
+
+//cell holds a local variable that closures capture, so assignments are seen by everyone sharing it
+
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Inspect() string {
+	if c.Value == nil {
+		return "cell()"
+	}
+	return "cell(" + c.Value.Inspect() + ")"
+}
+
+func (c *Cell) Type() string {
+	return CELL_OBJ
+}
@@ -0,0 +1,527 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+//conversion between Go values and PLZ objects, for programs embedding PLZ
+
+var (
+	objectType = reflect.TypeOf((*Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+//goRef identifies a Go slice, map or pointer, so a value that refers back to itself converts
+//to an object that does the same instead of recursing forever
+type goRef struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+//goTarget identifies an object converted to a Go type, or to its natural Go type if typ is nil,
+//for the same reason
+type goTarget struct {
+	obj Object
+	typ reflect.Type
+}
+
+//FromGo converts a Go value to a PLZ object. Numbers, strings and bools convert to the matching
+//PLZ type, nil and nil pointers to Null, slices and arrays to arrays, and maps to hashes. Structs
+//become hashes of their exported fields, named by a `plz:"name"` tag if they have one, and funcs
+//become builtins that convert their arguments and results. Objects are returned as they are
+func FromGo(value interface{}) (Object, error) {
+	if value == nil {
+		return &Null{}, nil
+	}
+	return fromGo(reflect.ValueOf(value), make(map[goRef]Object))
+}
+
+//fromGo converts v. seen maps the slices, maps and pointers converted so far to their objects
+func fromGo(v reflect.Value, seen map[goRef]Object) (Object, error) {
+	if v.IsValid() && v.Type().Implements(objectType) && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+		if obj, ok := v.Interface().(Object); ok {
+			return obj, nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return &Null{}, nil
+	case reflect.Bool:
+		return &Boolean{Value: v.Bool()}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("cannot convert %d to INTEGER: too large", v.Uint())
+		}
+		return &Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return &Null{}, nil
+		}
+		return fromGo(v.Elem(), seen)
+	case reflect.Ptr:
+		if v.IsNil() {
+			return &Null{}, nil
+		}
+		ref := goRef{ptr: v.Pointer(), typ: v.Type()}
+		if obj, ok := seen[ref]; ok {
+			return obj, nil
+		}
+		switch v.Elem().Kind() {
+		case reflect.Struct:
+			hash := NewHashMap()
+			seen[ref] = hash
+			return hash, structFromGo(v.Elem(), hash, seen)
+		case reflect.Array:
+			arr := &Array{}
+			seen[ref] = arr
+			return arr, arrayFromGo(v.Elem(), arr, seen)
+		}
+		return fromGo(v.Elem(), seen)
+	case reflect.Slice:
+		if v.IsNil() {
+			return &Null{}, nil
+		}
+		ref := goRef{ptr: v.Pointer(), typ: v.Type(), len: v.Len()}
+		if obj, ok := seen[ref]; ok {
+			return obj, nil
+		}
+		arr := &Array{}
+		seen[ref] = arr
+		return arr, arrayFromGo(v, arr, seen)
+	case reflect.Array:
+		arr := &Array{}
+		return arr, arrayFromGo(v, arr, seen)
+	case reflect.Map:
+		if v.IsNil() {
+			return &Null{}, nil
+		}
+		ref := goRef{ptr: v.Pointer(), typ: v.Type()}
+		if obj, ok := seen[ref]; ok {
+			return obj, nil
+		}
+		hash := NewHashMap()
+		seen[ref] = hash
+		return hash, mapFromGo(v, hash, seen)
+	case reflect.Struct:
+		hash := NewHashMap()
+		return hash, structFromGo(v, hash, seen)
+	case reflect.Func:
+		if v.IsNil() {
+			return &Null{}, nil
+		}
+		return funcFromGo(v), nil
+	default:
+		return nil, fmt.Errorf("cannot convert Go %s to a PLZ value", v.Type())
+	}
+}
+
+//arrayFromGo fills arr with the elements of a slice or array
+func arrayFromGo(v reflect.Value, arr *Array, seen map[goRef]Object) error {
+	arr.Elements = make([]Object, v.Len())
+	for i := range arr.Elements {
+		element, err := fromGo(v.Index(i), seen)
+		if err != nil {
+			return err
+		}
+		arr.Elements[i] = element
+	}
+	return nil
+}
+
+//mapFromGo fills hash from a map, ordering it by key since Go maps have no order of their own
+func mapFromGo(v reflect.Value, hash *HashMap, seen map[goRef]Object) error {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(a, b int) bool { return lessKey(keys[a], keys[b]) })
+
+	for _, key := range keys {
+		k, err := fromGo(key, seen)
+		if err != nil {
+			return err
+		}
+		hashable, ok := k.(Hashable)
+		if !ok {
+			return fmt.Errorf("cannot convert map with %s keys: not hashable", k.Type())
+		}
+		value, err := fromGo(v.MapIndex(key), seen)
+		if err != nil {
+			return err
+		}
+		hash.Set(hashable, value)
+	}
+	return nil
+}
+
+func lessKey(a reflect.Value, b reflect.Value) bool {
+	for a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	for b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+	if a.Kind() == b.Kind() {
+		switch a.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return a.Uint() < b.Uint()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		case reflect.String:
+			return a.String() < b.String()
+		case reflect.Bool:
+			return !a.Bool() && b.Bool()
+		}
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+//structFromGo fills hash with the fields of a struct
+func structFromGo(v reflect.Value, hash *HashMap, seen map[goRef]Object) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := fieldName(t.Field(i))
+		if !ok {
+			continue
+		}
+		value, err := fromGo(v.Field(i), seen)
+		if err != nil {
+			return err
+		}
+		hash.Set(&String{Value: name}, value)
+	}
+	return nil
+}
+
+//fieldName is the hash key for a struct field, reporting false for fields that aren't converted
+func fieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" { //unexported
+		return "", false
+	}
+	tag := field.Tag.Get("plz")
+	if tag == "-" {
+		return "", false
+	}
+	if tag != "" {
+		return tag, true
+	}
+	return field.Name, true
+}
+
+//funcFromGo wraps a Go func as a builtin. Its arguments are converted with ToGo and its results
+//with FromGo; a non-nil error as the last result is raised as a PLZ error, and several other
+//results are returned as an array
+func funcFromGo(fn reflect.Value) *BuiltIn {
+	t := fn.Type()
+
+	return &BuiltIn{Fn: func(args ...Object) Object {
+		numIn := t.NumIn()
+		if t.IsVariadic() {
+			if err := CheckArgCount(args, numIn-1, -1); err != nil {
+				return err
+			}
+		} else if err := CheckArgCount(args, numIn, numIn); err != nil {
+			return err
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var argType reflect.Type
+			if t.IsVariadic() && i >= numIn-1 {
+				argType = t.In(numIn - 1).Elem()
+			} else {
+				argType = t.In(i)
+			}
+
+			in[i] = reflect.New(argType).Elem()
+			if err := toGo(arg, in[i], make(map[goTarget]reflect.Value)); err != nil {
+				return &Error{Message: fmt.Sprintf("Invalid %s argument: %s", ordinal(i+1), err), Kind: TYPE_ERROR}
+			}
+		}
+
+		out := fn.Call(in)
+		if len(out) > 0 && t.Out(len(out)-1) == errorType {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return &Error{Message: err.Error()}
+			}
+			out = out[:len(out)-1]
+		}
+
+		results := make([]Object, len(out))
+		for i, value := range out {
+			result, err := fromGo(value, make(map[goRef]Object))
+			if err != nil {
+				return &Error{Message: err.Error(), Kind: TYPE_ERROR}
+			}
+			results[i] = result
+		}
+
+		switch len(results) {
+		case 0:
+			return &Null{}
+		case 1:
+			return results[0]
+		default:
+			return &Array{Elements: results}
+		}
+	}}
+}
+
+//ToGo stores obj in the Go value target points to, converting it to target's type. Arrays fill
+//slices and arrays, hashes fill maps and structs (by field name or `plz` tag, leaving fields
+//without a key alone) and Null sets pointers, slices, maps and interfaces to nil. Converting to
+//interface{} gives int64, float64, string, bool, []interface{} or map[string]interface{}
+//(map[interface{}]interface{} if some keys aren't strings). A nil obj converts like Null
+func ToGo(obj Object, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("cannot convert to %T: target must be a non-nil pointer", target)
+	}
+	return toGo(obj, v.Elem(), make(map[goTarget]reflect.Value))
+}
+
+//toGo stores obj in dst. seen maps the arrays and hashes converted so far, with the type they
+//were converted to, to the Go values made for them
+func toGo(obj Object, dst reflect.Value, seen map[goTarget]reflect.Value) error {
+	if obj == nil {
+		obj = &Null{}
+	}
+	if ho, ok := obj.(*HashObject); ok {
+		obj = ho.Inner
+	}
+	if made, ok := seen[goTarget{obj, dst.Type()}]; ok {
+		dst.Set(made)
+		return nil
+	}
+
+	if reflect.TypeOf(obj).AssignableTo(dst.Type()) && dst.Type() != reflect.TypeOf((*interface{})(nil)).Elem() {
+		dst.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	if _, ok := obj.(*Null); ok {
+		switch dst.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+	}
+
+	mismatch := fmt.Errorf("cannot convert %s to %s", obj.Type(), dst.Type())
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			return mismatch
+		}
+		value, err := naturalGo(obj, seen)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			dst.Set(reflect.Zero(dst.Type()))
+		} else {
+			dst.Set(reflect.ValueOf(value))
+		}
+	case reflect.Bool:
+		b, ok := obj.(*Boolean)
+		if !ok {
+			return mismatch
+		}
+		dst.SetBool(b.Value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch
+		}
+		if dst.OverflowInt(i.Value) {
+			return fmt.Errorf("cannot convert %d to %s: out of range", i.Value, dst.Type())
+		}
+		dst.SetInt(i.Value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch
+		}
+		if i.Value < 0 || dst.OverflowUint(uint64(i.Value)) {
+			return fmt.Errorf("cannot convert %d to %s: out of range", i.Value, dst.Type())
+		}
+		dst.SetUint(uint64(i.Value))
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *Integer:
+			dst.SetFloat(float64(n.Value))
+		case *Float:
+			dst.SetFloat(n.Value)
+		default:
+			return mismatch
+		}
+	case reflect.String:
+		s, ok := obj.(*String)
+		if !ok {
+			return mismatch
+		}
+		dst.SetString(s.Value)
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		if isContainer(obj) {
+			seen[goTarget{obj, dst.Type()}] = dst
+		}
+		return toGo(obj, dst.Elem(), seen)
+	case reflect.Slice:
+		arr, ok := obj.(*Array)
+		if !ok {
+			return mismatch
+		}
+		slice := reflect.MakeSlice(dst.Type(), len(arr.Elements), len(arr.Elements))
+		seen[goTarget{obj, dst.Type()}] = slice
+		for i, element := range arr.Elements {
+			if err := toGo(element, slice.Index(i), seen); err != nil {
+				return err
+			}
+		}
+		dst.Set(slice)
+	case reflect.Array:
+		arr, ok := obj.(*Array)
+		if !ok {
+			return mismatch
+		}
+		if len(arr.Elements) != dst.Len() {
+			return fmt.Errorf("cannot convert ARRAY of length %d to %s", len(arr.Elements), dst.Type())
+		}
+		for i, element := range arr.Elements {
+			if err := toGo(element, dst.Index(i), seen); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		hash, ok := obj.(*HashMap)
+		if !ok {
+			return mismatch
+		}
+		m := reflect.MakeMapWithSize(dst.Type(), hash.Len())
+		seen[goTarget{obj, dst.Type()}] = m
+		for _, pair := range hash.Items() {
+			key := reflect.New(dst.Type().Key()).Elem()
+			if err := toGo(pair.Key, key, seen); err != nil {
+				return err
+			}
+			value := reflect.New(dst.Type().Elem()).Elem()
+			if err := toGo(pair.Value, value, seen); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		dst.Set(m)
+	case reflect.Struct:
+		hash, ok := obj.(*HashMap)
+		if !ok {
+			return mismatch
+		}
+		t := dst.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := fieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+			pair, ok := hash.Get(&String{Value: name})
+			if !ok {
+				continue
+			}
+			if err := toGo(pair.Value, dst.Field(i), seen); err != nil {
+				return fmt.Errorf("field %s: %s", name, err)
+			}
+		}
+	default:
+		return mismatch
+	}
+	return nil
+}
+
+//naturalGo converts obj to the Go type closest to it
+func naturalGo(obj Object, seen map[goTarget]reflect.Value) (interface{}, error) {
+	if made, ok := seen[goTarget{obj: obj}]; ok {
+		return made.Interface(), nil
+	}
+
+	switch obj := obj.(type) {
+	case nil, *Null:
+		return nil, nil
+	case *HashObject:
+		return naturalGo(obj.Inner, seen)
+	case *Boolean:
+		return obj.Value, nil
+	case *Integer:
+		return obj.Value, nil
+	case *Float:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Array:
+		elements := make([]interface{}, len(obj.Elements))
+		seen[goTarget{obj: obj}] = reflect.ValueOf(elements)
+		for i, element := range obj.Elements {
+			value, err := naturalGo(element, seen)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *HashMap:
+		allStrings := true
+		for _, pair := range obj.Items() {
+			if _, ok := pair.Key.(*String); !ok {
+				allStrings = false
+			}
+		}
+
+		if allStrings {
+			m := make(map[string]interface{}, obj.Len())
+			seen[goTarget{obj: obj}] = reflect.ValueOf(m)
+			for _, pair := range obj.Items() {
+				value, err := naturalGo(pair.Value, seen)
+				if err != nil {
+					return nil, err
+				}
+				m[pair.Key.(*String).Value] = value
+			}
+			return m, nil
+		}
+
+		m := make(map[interface{}]interface{}, obj.Len())
+		seen[goTarget{obj: obj}] = reflect.ValueOf(m)
+		for _, pair := range obj.Items() {
+			key, _ := naturalGo(pair.Key, seen)
+			value, err := naturalGo(pair.Value, seen)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	default:
+		return obj, nil //functions and the like stay PLZ objects
+	}
+}
+
+//isContainer reports whether obj is an array or hash, which can hold itself
+func isContainer(obj Object) bool {
+	switch obj.(type) {
+	case *Array, *HashMap:
+		return true
+	}
+	return false
+}
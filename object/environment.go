@@ -1,19 +1,47 @@
 package object
 
+import "sort"
+
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store    map[string]Object
+	outer    *Environment
+	settings *envSettings //shared with enclosed environments
+}
+
+//envSettings hold what an environment shares with the environments enclosed by it, so that
+//functions defined by one run see the budget and builtins of the run calling them
+type envSettings struct {
+	budget   *Budget
+	builtins map[string]*BuiltIn //nil means the evaluator's defaults
 }
 
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s}
+	return &Environment{store: s, settings: &envSettings{}}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: outer, settings: outer.settings}
+}
+
+//SetBudget limits evaluation in this environment and the environments enclosed by it
+func (e *Environment) SetBudget(budget *Budget) {
+	e.settings.budget = budget
+}
+
+func (e *Environment) Budget() *Budget {
+	return e.settings.budget
+}
+
+//SetBuiltins makes table the builtin functions visible in this environment and the
+//environments enclosed by it
+func (e *Environment) SetBuiltins(table map[string]*BuiltIn) {
+	e.settings.builtins = table
+}
+
+func (e *Environment) Builtins() map[string]*BuiltIn {
+	return e.settings.builtins
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -28,3 +56,25 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+//Assign rebinds name in the nearest environment that already defines it, reporting false if none does
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
+
+//Names returns the names bound directly in this environment, sorted
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,111 @@
+package object
+
+import (
+	"context"
+	"fmt"
+)
+
+//Limits bounds the resources a run may use. A zero field means no limit
+type Limits struct {
+	MaxSteps      int64           //evaluation steps, roughly one per node or instruction
+	MaxDepth      int             //nested function calls
+	MaxAllocation int             //length of a single string, array or hash
+	Context       context.Context //the run stops once it is done, e.g. at its deadline
+}
+
+//DefaultLimits only bounds call depth, so runaway recursion is an error instead of a crash
+func DefaultLimits() Limits {
+	return Limits{MaxDepth: 10000}
+}
+
+//how many steps pass between checks of the context, which are comparatively slow
+const contextCheckInterval = 256
+
+//Budget tracks a single run against its Limits. A nil *Budget allows everything
+type Budget struct {
+	limits Limits
+	steps  int64
+	depth  int
+}
+
+func NewBudget(limits Limits) *Budget {
+	return &Budget{limits: limits}
+}
+
+//Step counts one evaluation step, returning an error once the run is out of steps or time
+func (b *Budget) Step() *Error {
+	if b == nil {
+		return nil
+	}
+
+	b.steps++
+	if b.limits.MaxSteps > 0 && b.steps > b.limits.MaxSteps {
+		return limitError("step limit exceeded: more than %d steps", b.limits.MaxSteps)
+	}
+
+	if b.limits.Context != nil && b.steps%contextCheckInterval == 0 {
+		switch b.limits.Context.Err() {
+		case nil:
+		case context.DeadlineExceeded:
+			return limitError("time limit exceeded")
+		default:
+			return limitError("execution cancelled")
+		}
+	}
+
+	return nil
+}
+
+//Enter records a function call, returning an error if calls are nested too deeply
+func (b *Budget) Enter() *Error {
+	if b == nil {
+		return nil
+	}
+
+	if b.limits.MaxDepth > 0 && b.depth >= b.limits.MaxDepth {
+		return limitError("maximum call depth exceeded: more than %d nested calls", b.limits.MaxDepth)
+	}
+	b.depth++
+	return nil
+}
+
+//MaxDepth is how deeply calls may be nested, 0 if there is no limit
+func (b *Budget) MaxDepth() int {
+	if b == nil {
+		return 0
+	}
+	return b.limits.MaxDepth
+}
+
+//Leave records a function returning
+func (b *Budget) Leave() {
+	if b != nil && b.depth > 0 {
+		b.depth--
+	}
+}
+
+//CheckAllocation returns an error if obj is a string, array or hash larger than allowed
+func (b *Budget) CheckAllocation(obj Object) *Error {
+	if b == nil || b.limits.MaxAllocation <= 0 {
+		return nil
+	}
+
+	size := 0
+	switch obj := obj.(type) {
+	case *String:
+		size = len(obj.Value)
+	case *Array:
+		size = len(obj.Elements)
+	case *HashMap:
+		size = obj.Len()
+	}
+
+	if size > b.limits.MaxAllocation {
+		return limitError("allocation limit exceeded: size %d is over the limit of %d", size, b.limits.MaxAllocation)
+	}
+	return nil
+}
+
+func limitError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Kind: LIMIT_ERROR}
+}
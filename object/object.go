@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/MYKatz/PLZ/ast"
+	"github.com/MYKatz/PLZ/token"
 )
 
 type ObjectType string
@@ -15,9 +19,12 @@ type BuiltinFunction func(args ...Object) Object
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	STRING_OBJ       = "STRING"
@@ -46,6 +53,24 @@ func (i *Integer) Type() string {
 	return INTEGER_OBJ
 }
 
+//float
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Inspect() string {
+	out := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(out, ".eIN") { //keep 2.0 distinguishable from the integer 2
+		out += ".0"
+	}
+	return out
+}
+
+func (f *Float) Type() string {
+	return FLOAT_OBJ
+}
+
 //boolean
 
 type Boolean struct {
@@ -86,13 +111,109 @@ func (rv *ReturnValue) Type() string {
 	return RETURN_VALUE_OBJ
 }
 
+//break and continue travel up through block statements to the enclosing loop
+
+type Break struct{}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+func (b *Break) Type() string {
+	return BREAK_OBJ
+}
+
+type Continue struct{}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+func (c *Continue) Type() string {
+	return CONTINUE_OBJ
+}
+
 //error
 
 type Error struct {
 	Message string
+	Kind    string         //error type seen by scripts; inferred from Message when empty
+	Pos     token.Position //where the error was raised, if known
+	Stack   []StackFrame   //calls still running when the error was raised, innermost first
+}
+
+//error types scripts can catch and raise
+const (
+	PLAIN_ERROR = "Error"
+	TYPE_ERROR  = "TypeError"
+	NAME_ERROR  = "NameError"
+	INDEX_ERROR = "IndexError"
+	VALUE_ERROR = "ValueError"
+	LIMIT_ERROR = "LimitError" //a run went over its Limits; can't be caught
+)
+
+//errorKinds infers the type of built-in errors from the start of their message
+var errorKinds = []struct {
+	prefix string
+	kind   string
+}{
+	{"type mismatch", TYPE_ERROR},
+	{"unknown operator", TYPE_ERROR},
+	{"index type not supported", TYPE_ERROR},
+	{"index operator not supported", TYPE_ERROR},
+	{"index assignment not supported", TYPE_ERROR},
+	{"not hashable", TYPE_ERROR},
+	{"not a function", TYPE_ERROR},
+	{"cannot iterate over", TYPE_ERROR},
+	{"wrong number of arguments", TYPE_ERROR},
+	{"Incorrect number of arguments", TYPE_ERROR},
+	{"Invalid", TYPE_ERROR},
+	{"identifier not found", NAME_ERROR},
+	{"cannot assign to undefined variable", NAME_ERROR},
+	{"Invalid let statement", NAME_ERROR},
+	{"index out of range", INDEX_ERROR},
+	{"Could not convert", VALUE_ERROR},
+	{"division by zero", VALUE_ERROR},
+}
+
+//ErrorType returns the error's type, e.g. "TypeError"
+func (e *Error) ErrorType() string {
+	if e.Kind != "" {
+		return e.Kind
+	}
+
+	kind := PLAIN_ERROR
+	for _, candidate := range errorKinds {
+		if strings.HasPrefix(e.Message, candidate.prefix) {
+			kind = candidate.kind //later, more specific prefixes win
+		}
+	}
+	return kind
+}
+
+//Catchable reports whether a try expression may handle the error
+func (e *Error) Catchable() bool {
+	return e.Kind != LIMIT_ERROR
+}
+
+//StackFrame is a call to a PLZ function
+type StackFrame struct {
+	Function string         //name the function was bound to, or "<anonymous>"
+	CallSite token.Position //where it was called from
+}
+
+//FunctionName names a function in stack traces
+func FunctionName(name string) string {
+	if name == "" {
+		return "<anonymous>"
+	}
+	return name
 }
 
 func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": Error: " + e.Message
+	}
 	return "Error: " + e.Message
 }
 
@@ -100,10 +221,57 @@ func (e *Error) Type() string {
 	return ERROR_OBJ
 }
 
+//how many identical traceback lines are printed before the rest are summarised
+const tracebackRepeats = 3
+
+//Traceback formats the error like a Python traceback, outermost call first. Errors raised
+//outside any function format as Inspect does. Calls made by host code have no call site, so
+//they start the traceback at the function called
+func (e *Error) Traceback() string {
+	if len(e.Stack) == 0 {
+		return e.Inspect()
+	}
+
+	lines := []string{}
+	function := "<program>"
+	for i := len(e.Stack) - 1; i >= 0; i-- {
+		if e.Stack[i].CallSite.IsValid() {
+			lines = append(lines, fmt.Sprintf("  %s, in %s", e.Stack[i].CallSite, function))
+		}
+		function = e.Stack[i].Function
+	}
+	if e.Pos.IsValid() {
+		lines = append(lines, fmt.Sprintf("  %s, in %s", e.Pos, function))
+	}
+
+	var out bytes.Buffer
+	out.WriteString("Traceback (most recent call last):\n")
+	for i := 0; i < len(lines); {
+		repeats := 1
+		for i+repeats < len(lines) && lines[i+repeats] == lines[i] {
+			repeats++
+		}
+
+		for j := 0; j < repeats && j < tracebackRepeats; j++ {
+			out.WriteString(lines[i] + "\n")
+		}
+		if repeats > tracebackRepeats {
+			fmt.Fprintf(&out, "  [previous line repeated %d more times]\n", repeats-tracebackRepeats)
+		}
+		i += repeats
+	}
+	out.WriteString("Error: " + e.Message)
+
+	return out.String()
+}
+
 //function
 
 type Function struct {
+	Name       string
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression //evaluated when the matching argument is left out
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -146,28 +314,52 @@ func (f *BuiltIn) Type() string {
 
 //array
 
+//Arrays made by Append can share a backing store with the array they were appended to. Elements
+//should only be changed through Set, which copies a shared store first
 type Array struct {
 	Elements []Object
+	store    *arrayStore //set when Elements may have room to grow into, see Append
+	shared   bool        //another array uses the same backing store
+}
+
+//arrayStore counts the slots of a backing store that arrays already use
+type arrayStore struct {
+	used int
 }
 
 func (a *Array) Inspect() string {
-	var output bytes.Buffer
+	text, _ := InspectLimit(a, 0)
+	return text
+}
 
-	elements := []string{}
+func (a *Array) Type() string {
+	return ARRAY_OBJ
+}
 
-	for _, el := range a.Elements {
-		elements = append(elements, el.Inspect())
+//Append returns a new array of a's elements followed by element, leaving a as it was. When a is
+//the longest array using its backing store and the store has room, the new array grows into it
+//and both are marked shared, so appending in a loop takes amortized constant time
+func (a *Array) Append(element Object) *Array {
+	n := len(a.Elements)
+	if a.store != nil && a.store.used == n && n < cap(a.Elements) {
+		a.store.used++
+		a.shared = true
+		return &Array{Elements: append(a.Elements, element), store: a.store, shared: true}
 	}
 
-	output.WriteString("[")
-	output.WriteString(strings.Join(elements, ", "))
-	output.WriteString("]")
-
-	return output.String()
+	elements := append(a.Elements[:n:n], element)
+	return &Array{Elements: elements, store: &arrayStore{used: len(elements)}}
 }
 
-func (a *Array) Type() string {
-	return ARRAY_OBJ
+//Set stores value at index i, which must be in range, copying the elements first if another
+//array shares them
+func (a *Array) Set(i int, value Object) {
+	if a.shared {
+		elements := make([]Object, len(a.Elements))
+		copy(elements, a.Elements)
+		a.Elements, a.store, a.shared = elements, nil, false
+	}
+	a.Elements[i] = value
 }
 
 //hashkey
@@ -178,6 +370,7 @@ type HashKey struct {
 }
 
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
@@ -199,11 +392,33 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: INTEGER_OBJ, Value: uint64(i.Value)}
 }
 
+//whole floats hash like the equal integer, so {1: x}[1.0] finds x just as 1 == 1.0
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && math.Abs(f.Value) < math.MaxInt64 {
+		return HashKey{Type: INTEGER_OBJ, Value: uint64(int64(f.Value))}
+	}
+	return HashKey{Type: FLOAT_OBJ, Value: math.Float64bits(f.Value)}
+}
+
 func (s *String) HashKey() HashKey {
+	return HashKey{Type: STRING_OBJ, Value: stringHash(s.Value)}
+}
+
+//stringHash hashes string keys. It is a variable so tests can force collisions
+var stringHash = func(s string) uint64 {
 	h := fnv.New64a()
-	h.Write([]byte(s.Value))
+	h.Write([]byte(s))
+	return h.Sum64()
+}
 
-	return HashKey{Type: STRING_OBJ, Value: h.Sum64()}
+//sameKey reports whether two keys with equal hash keys are the same key. Strings are the only
+//keys whose HashKey can collide; for booleans and numbers it is exact
+func sameKey(a Object, b Object) bool {
+	if as, ok := a.(*String); ok {
+		bs, ok := b.(*String)
+		return ok && as.Value == bs.Value
+	}
+	return true
 }
 
 //hashes
@@ -213,21 +428,145 @@ type HashPair struct {
 	Value Object
 }
 
+//HashMap keeps its pairs in the order their keys were first set, so printing and iterating
+//over a hash always visit them the same way
+//
+//Pairs are bucketed by HashKey and a lookup compares the keys in its bucket, so keys whose
+//hashes collide are still kept apart
 type HashMap struct {
-	Pairs map[HashKey]HashPair
+	buckets map[HashKey][]int //positions in pairs of the keys with each HashKey
+	pairs   []HashPair
+}
+
+func NewHashMap() *HashMap {
+	return &HashMap{buckets: make(map[HashKey][]int)}
+}
+
+func (hm *HashMap) Get(key Hashable) (HashPair, bool) {
+	i, ok := hm.find(key, key.HashKey())
+	if !ok {
+		return HashPair{}, false
+	}
+	return hm.pairs[i], true
+}
+
+//Set stores value under key. A key that is already present keeps its place in the order
+func (hm *HashMap) Set(key Hashable, value Object) {
+	if hm.buckets == nil {
+		hm.buckets = make(map[HashKey][]int)
+	}
+
+	hashed := key.HashKey()
+	if i, ok := hm.find(key, hashed); ok {
+		hm.pairs[i].Value = value
+		return
+	}
+	hm.buckets[hashed] = append(hm.buckets[hashed], len(hm.pairs))
+	hm.pairs = append(hm.pairs, HashPair{Key: key, Value: value})
+}
+
+func (hm *HashMap) find(key Hashable, hashed HashKey) (int, bool) {
+	for _, i := range hm.buckets[hashed] {
+		if sameKey(hm.pairs[i].Key, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (hm *HashMap) Len() int {
+	return len(hm.pairs)
+}
+
+//Items returns the pairs in insertion order. The slice must not be modified
+func (hm *HashMap) Items() []HashPair {
+	return hm.pairs
 }
 
 func (hm *HashMap) Inspect() string {
+	text, _ := InspectLimit(hm, 0)
+	return text
+}
 
-	var output bytes.Buffer
+//InspectLimit formats obj like Inspect, but stops once the text passes max bytes, returning its
+//start and true if it was cut short. There is no limit if max is 0
+func InspectLimit(obj Object, max int) (string, bool) {
+	f := &formatter{max: max, inProgress: make(map[Object]bool)}
+	f.inspect(obj)
 
-	output.WriteString("{")
-	for _, pair := range hm.Pairs {
-		output.WriteString(pair.Key.Inspect() + ":" + pair.Value.Inspect() + ",")
+	text := f.output.String()
+	if max <= 0 || len(text) <= max {
+		return text, false
+	}
+	for max > 0 && !utf8.RuneStart(text[max]) {
+		max-- //don't split a character
 	}
-	output.WriteString("}")
+	return text[:max], true
+}
 
-	return output.String()
+//formatter writes the text of objects, showing an array or hash inside itself as [...] or {...}
+//rather than recursing forever. inProgress holds the containers being formatted
+type formatter struct {
+	output     bytes.Buffer
+	max        int
+	inProgress map[Object]bool
+}
+
+//full reports whether the output has passed its limit, after which nothing more is written
+func (f *formatter) full() bool {
+	return f.max > 0 && f.output.Len() > f.max
+}
+
+func (f *formatter) inspect(obj Object) {
+	if ho, ok := obj.(*HashObject); ok {
+		obj = ho.Inner
+	}
+	if f.full() {
+		return
+	}
+
+	switch obj := obj.(type) {
+	case *Array:
+		if f.inProgress[obj] {
+			f.output.WriteString("[...]")
+			return
+		}
+		f.inProgress[obj] = true
+		defer delete(f.inProgress, obj)
+
+		f.output.WriteString("[")
+		for i, el := range obj.Elements {
+			if f.full() {
+				return
+			}
+			if i > 0 {
+				f.output.WriteString(", ")
+			}
+			f.inspect(el)
+		}
+		f.output.WriteString("]")
+	case *HashMap:
+		if f.inProgress[obj] {
+			f.output.WriteString("{...}")
+			return
+		}
+		f.inProgress[obj] = true
+		defer delete(f.inProgress, obj)
+
+		f.output.WriteString("{")
+		for _, pair := range obj.pairs {
+			if f.full() {
+				return
+			}
+			f.inspect(pair.Key)
+			f.output.WriteString(":")
+			f.inspect(pair.Value)
+			f.output.WriteString(",")
+		}
+		f.output.WriteString("}")
+	default:
+		f.output.WriteString(obj.Inspect())
+	}
 }
 
 func (hm *HashMap) Type() string {
@@ -237,8 +576,7 @@ func (hm *HashMap) Type() string {
 //wrapper for objects accessed via Hash indexing
 type HashObject struct {
 	Hash     *HashMap
-	PlainKey Object
-	Key      HashKey
+	PlainKey Object //the key as written, since its HashKey alone may collide
 	Inner    Object
 }
 
@@ -1,7 +1,11 @@
 package object
 
 import (
+	"errors"
+	"reflect"
 	"testing"
+
+	"github.com/MYKatz/PLZ/token"
 )
 
 func TestStringHashKey(t *testing.T) {
@@ -22,3 +26,430 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("Strings w/ different content have same hashkeys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	half1 := &Float{Value: 0.5}
+	half2 := &Float{Value: 0.5}
+	whole := &Float{Value: 2}
+
+	if half1.HashKey() != half2.HashKey() {
+		t.Errorf("Floats w/ same value have different hashkeys")
+	}
+
+	if whole.HashKey() != (&Integer{Value: 2}).HashKey() {
+		t.Errorf("Whole float has different hashkey from equal integer")
+	}
+
+	if half1.HashKey() == (&Integer{Value: 0}).HashKey() {
+		t.Errorf("Fractional float has same hashkey as integer")
+	}
+}
+
+func TestHashMapOrder(t *testing.T) {
+	hash := NewHashMap()
+	hash.Set(&String{Value: "z"}, &Integer{Value: 1})
+	hash.Set(&Integer{Value: 3}, &Integer{Value: 2})
+	hash.Set(&String{Value: "a"}, &Integer{Value: 3})
+	hash.Set(&String{Value: "z"}, &Integer{Value: 4}) //overwriting keeps the original position
+
+	expected := "{z:4,3:2,a:3,}"
+	for i := 0; i < 10; i++ { //map iteration order would differ between runs
+		if hash.Inspect() != expected {
+			t.Fatalf("Incorrect inspect. Expected %q, received %q", expected, hash.Inspect())
+		}
+	}
+
+	if hash.Len() != 3 {
+		t.Errorf("Incorrect length. Expected 3, received %d", hash.Len())
+	}
+
+	pair, ok := hash.Get(&Float{Value: 3})
+	if !ok || pair.Value.Inspect() != "2" {
+		t.Errorf("Incorrect pair for 3.0, received %+v", pair)
+	}
+}
+
+func TestHashMapCollisions(t *testing.T) {
+	defer func(original func(string) uint64) { stringHash = original }(stringHash)
+	stringHash = func(string) uint64 { return 42 } //every string collides
+
+	first, second := &String{Value: "first"}, &String{Value: "second"}
+	if first.HashKey() != second.HashKey() {
+		t.Fatalf("hash function was not replaced")
+	}
+
+	hash := NewHashMap()
+	hash.Set(first, &Integer{Value: 1})
+	hash.Set(second, &Integer{Value: 2})
+	hash.Set(&Integer{Value: 42}, &Integer{Value: 3}) //same hash value, different type
+	hash.Set(&String{Value: "first"}, &Integer{Value: 4})
+
+	tests := []struct {
+		key      Hashable
+		expected string
+	}{
+		{&String{Value: "first"}, "4"},
+		{&String{Value: "second"}, "2"},
+		{&Integer{Value: 42}, "3"},
+		{&String{Value: "third"}, ""},
+	}
+
+	for _, tt := range tests {
+		pair, ok := hash.Get(tt.key)
+		if tt.expected == "" {
+			if ok {
+				t.Errorf("Unexpected pair for %s: %s", tt.key.Inspect(), pair.Value.Inspect())
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("No pair for %s", tt.key.Inspect())
+			continue
+		}
+		if pair.Value.Inspect() != tt.expected {
+			t.Errorf("Incorrect value for %s. Expected %s, received %s", tt.key.Inspect(), tt.expected, pair.Value.Inspect())
+		}
+	}
+
+	if hash.Len() != 3 {
+		t.Errorf("Incorrect length. Expected 3, received %d", hash.Len())
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{2, "2.0"},
+		{3.25, "3.25"},
+		{-0.5, "-0.5"},
+		{1e21, "1e+21"},
+	}
+
+	for _, tt := range tests {
+		if out := (&Float{Value: tt.value}).Inspect(); out != tt.expected {
+			t.Errorf("Incorrect Inspect. Expected %q, received %q", tt.expected, out)
+		}
+	}
+}
+
+func TestTraceback(t *testing.T) {
+	err := &Error{
+		Message: "type mismatch: INTEGER + BOOLEAN",
+		Pos:     token.Position{Filename: "a.plz", Line: 2, Column: 3},
+		Stack: []StackFrame{
+			{Function: "inner", CallSite: token.Position{Filename: "a.plz", Line: 5, Column: 3}},
+			{Function: "<anonymous>", CallSite: token.Position{Filename: "a.plz", Line: 7, Column: 1}},
+		},
+	}
+
+	expected := `Traceback (most recent call last):
+  a.plz:7:1, in <program>
+  a.plz:5:3, in <anonymous>
+  a.plz:2:3, in inner
+Error: type mismatch: INTEGER + BOOLEAN`
+	if err.Traceback() != expected {
+		t.Errorf("Incorrect traceback. Expected:\n%s\nReceived:\n%s", expected, err.Traceback())
+	}
+
+	recursive := &Error{Message: "too deep", Pos: token.Position{Line: 1, Column: 20}}
+	for i := 0; i < 10; i++ {
+		recursive.Stack = append(recursive.Stack, StackFrame{Function: "f", CallSite: token.Position{Line: 1, Column: 20}})
+	}
+	expected = `Traceback (most recent call last):
+  1:20, in <program>
+  1:20, in f
+  1:20, in f
+  1:20, in f
+  [previous line repeated 7 more times]
+Error: too deep`
+	if recursive.Traceback() != expected {
+		t.Errorf("Incorrect traceback. Expected:\n%s\nReceived:\n%s", expected, recursive.Traceback())
+	}
+
+	plain := &Error{Message: "oops", Pos: token.Position{Line: 1, Column: 1}}
+	if plain.Traceback() != plain.Inspect() {
+		t.Errorf("Error outside a function should format as Inspect, received %q", plain.Traceback())
+	}
+}
+
+func TestCheckArgs(t *testing.T) {
+	one, two := &Integer{Value: 1}, &String{Value: "two"}
+
+	tests := []struct {
+		err      *Error
+		expected string
+	}{
+		{CheckArgCount([]Object{one}, 1, 1), ""},
+		{CheckArgCount([]Object{}, 1, 1), "Incorrect number of arguments. Expected 1, received 0"},
+		{CheckArgCount([]Object{one, one, one}, 1, 2), "Incorrect number of arguments. Expected 1 or 2, received 3"},
+		{CheckArgCount([]Object{}, 1, 3), "Incorrect number of arguments. Expected 1 to 3, received 0"},
+		{CheckArgCount([]Object{one, one, one}, 2, -1), ""},
+		{CheckArgCount([]Object{one}, 2, -1), "Incorrect number of arguments. Expected at least 2, received 1"},
+		{CheckArgTypes("f", []Object{one, two}, INTEGER_OBJ, STRING_OBJ), ""},
+		{CheckArgTypes("f", []Object{one, two}, INTEGER_OBJ, ANY), ""},
+		{CheckArgTypes("f", []Object{one, two, two}, INTEGER_OBJ), ""},
+		{CheckArgTypes("f", []Object{two}, INTEGER_OBJ), "Invalid argument to f. Expected integer, received STRING"},
+		{CheckArgTypes("f", []Object{one, one}, INTEGER_OBJ, STRING_OBJ), "Invalid second argument to f. Expected string, received INTEGER"},
+		{CheckArgs("f", []Object{one}, INTEGER_OBJ, STRING_OBJ), "Incorrect number of arguments. Expected 2, received 1"},
+		{CheckArgs("f", []Object{two}, INTEGER_OBJ), "Invalid argument to f. Expected integer, received STRING"},
+	}
+
+	for i, tt := range tests {
+		message := ""
+		if tt.err != nil {
+			message = tt.err.Message
+		}
+		if message != tt.expected {
+			t.Errorf("Test #%d: incorrect error. Expected %q, received %q", i, tt.expected, message)
+		}
+	}
+}
+
+func TestInspectCycles(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}, nil}}
+	array.Elements[1] = array
+	hash := NewHashMap()
+	hash.Set(&String{Value: "self"}, hash)
+	hash.Set(&String{Value: "array"}, array)
+	both := &Array{Elements: []Object{array, array}}
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{array, "[1, [...]]"},
+		{hash, "{self:{...},array:[1, [...]],}"},
+		{both, "[[1, [...]], [1, [...]]]"},
+	}
+
+	for i, tt := range tests {
+		if tt.obj.Inspect() != tt.expected {
+			t.Errorf("Test #%d: incorrect output. Expected %s, received %s", i, tt.expected, tt.obj.Inspect())
+		}
+	}
+}
+
+func TestInspectLimit(t *testing.T) {
+	nested := Object(&Array{Elements: []Object{&Integer{Value: 1}}})
+	for i := 0; i < 40; i++ { //2^40 ones if formatted in full
+		nested = &Array{Elements: []Object{nested, nested}}
+	}
+	hash := NewHashMap()
+	hash.Set(&String{Value: "a"}, &Integer{Value: 1})
+
+	tests := []struct {
+		obj       Object
+		max       int
+		expected  string
+		truncated bool
+	}{
+		{&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}, 0, "[1, 2]", false},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}, 6, "[1, 2]", false},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}, 5, "[1, 2", true},
+		{hash, 3, "{a:", true},
+		{&String{Value: "héllo"}, 2, "h", true},
+		{nested, 12, "[[[[[[[[[[[[", true},
+	}
+
+	for i, tt := range tests {
+		text, truncated := InspectLimit(tt.obj, tt.max)
+		if text != tt.expected || truncated != tt.truncated {
+			t.Errorf("Test #%d: incorrect output. Expected %q, %t, received %q, %t", i, tt.expected, tt.truncated, text, truncated)
+		}
+	}
+}
+
+func TestFromGo(t *testing.T) {
+	type point struct {
+		X      int
+		Y      int `plz:"y"`
+		Hidden int `plz:"-"`
+		label  string
+	}
+	var nilPointer *point
+
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "Null"},
+		{nilPointer, "Null"},
+		{true, "true"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{2.5, "2.5"},
+		{"hi", "hi"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{map[string]int{"b": 2, "a": 1, "c": 3}, "{a:1,b:2,c:3,}"},
+		{map[int]bool{3: true, 1: false}, "{1:false,3:true,}"},
+		{point{X: 1, Y: 2, Hidden: 3, label: "p"}, "{X:1,y:2,}"},
+		{&point{X: 4}, "{X:4,y:0,}"},
+		{[]interface{}{1, "two", nil}, "[1, two, Null]"},
+		{&Integer{Value: 5}, "5"},
+	}
+
+	for i, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Errorf("Test #%d: unexpected error %s", i, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("Test #%d: incorrect conversion. Expected %s, received %s", i, tt.expected, obj.Inspect())
+		}
+	}
+
+	for _, input := range []interface{}{make(chan int), complex(1, 2), map[[1]int]int{{1}: 1}, []interface{}{uint64(1 << 63)}} {
+		if _, err := FromGo(input); err == nil {
+			t.Errorf("no error converting %T", input)
+		}
+	}
+}
+
+func TestCyclicConversions(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+	}
+	loop := &node{Name: "a"}
+	loop.Next = &node{Name: "b", Next: loop}
+
+	obj, err := FromGo(loop)
+	hash, ok := obj.(*HashMap)
+	if err != nil || !ok {
+		t.Fatalf("incorrect conversion of a cyclic struct: %v, %v", obj, err)
+	}
+	next, _ := hash.Get(&String{Value: "Next"})
+	nextNext, _ := next.Value.(*HashMap).Get(&String{Value: "Next"})
+	if nextNext.Value != hash {
+		t.Errorf("the converted struct doesn't refer back to itself")
+	}
+
+	self := []interface{}{1, nil}
+	self[1] = self
+	obj, err = FromGo(self)
+	if arr, ok := obj.(*Array); err != nil || !ok || arr.Elements[1] != arr {
+		t.Errorf("incorrect conversion of a cyclic slice: %v", err)
+	}
+
+	array := &Array{Elements: []Object{&Integer{Value: 1}, nil}}
+	array.Elements[1] = array
+
+	var any interface{}
+	if err := ToGo(array, &any); err != nil || reflect.ValueOf(any.([]interface{})[1]).Pointer() != reflect.ValueOf(any).Pointer() {
+		t.Errorf("incorrect interface conversion of a cyclic array: %v", err)
+	}
+	var slice []interface{}
+	if err := ToGo(array, &slice); err != nil || len(slice) != 2 {
+		t.Errorf("incorrect slice conversion of a cyclic array: %v", err)
+	}
+}
+
+func TestFromGoFunc(t *testing.T) {
+	add, _ := FromGo(func(a int, b int) int { return a + b })
+	sum, _ := FromGo(func(label string, ns ...float64) (string, float64) {
+		total := 0.0
+		for _, n := range ns {
+			total += n
+		}
+		return label, total
+	})
+	fail, _ := FromGo(func() error { return errors.New("it broke") })
+	nothing, _ := FromGo(func() {})
+
+	one, two := &Integer{Value: 1}, &Integer{Value: 2}
+	tests := []struct {
+		fn       Object
+		args     []Object
+		expected string
+	}{
+		{add, []Object{one, two}, "3"},
+		{add, []Object{one}, "Error: Incorrect number of arguments. Expected 2, received 1"},
+		{add, []Object{one, &String{Value: "2"}}, "Error: Invalid second argument: cannot convert STRING to int"},
+		{sum, []Object{&String{Value: "total"}, one, &Float{Value: 0.5}}, "[total, 1.5]"},
+		{sum, []Object{&String{Value: "none"}}, "[none, 0.0]"},
+		{sum, []Object{}, "Error: Incorrect number of arguments. Expected at least 1, received 0"},
+		{fail, []Object{}, "Error: it broke"},
+		{nothing, []Object{}, "Null"},
+	}
+
+	for i, tt := range tests {
+		result := tt.fn.(*BuiltIn).Fn(tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("Test #%d: incorrect result. Expected %s, received %s", i, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestToGo(t *testing.T) {
+	type point struct {
+		X int
+		Y int `plz:"y"`
+	}
+	hash := NewHashMap()
+	hash.Set(&String{Value: "X"}, &Integer{Value: 1})
+	hash.Set(&String{Value: "y"}, &Integer{Value: 2})
+	array := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "two"}, &Null{}}}
+
+	var p point
+	if err := ToGo(hash, &p); err != nil || p != (point{X: 1, Y: 2}) {
+		t.Errorf("incorrect struct conversion: %+v, %v", p, err)
+	}
+
+	var m map[string]int
+	if err := ToGo(hash, &m); err != nil || len(m) != 2 || m["X"] != 1 || m["y"] != 2 {
+		t.Errorf("incorrect map conversion: %v, %v", m, err)
+	}
+
+	var any interface{}
+	if err := ToGo(array, &any); err != nil || !reflect.DeepEqual(any, []interface{}{int64(1), "two", nil}) {
+		t.Errorf("incorrect interface conversion: %#v, %v", any, err)
+	}
+
+	var f float64
+	if err := ToGo(&Integer{Value: 3}, &f); err != nil || f != 3 {
+		t.Errorf("incorrect float conversion: %v, %v", f, err)
+	}
+
+	var ip *int
+	if err := ToGo(&Integer{Value: 3}, &ip); err != nil || ip == nil || *ip != 3 {
+		t.Errorf("incorrect pointer conversion: %v, %v", ip, err)
+	}
+
+	var obj Object
+	if err := ToGo(array, &obj); err != nil || obj != array {
+		t.Errorf("incorrect Object conversion: %v, %v", obj, err)
+	}
+
+	//Run returns nil for statements without a value, which converts like Null
+	ip = new(int)
+	if err := ToGo(nil, &ip); err != nil || ip != nil {
+		t.Errorf("incorrect conversion of nil: %v, %v", ip, err)
+	}
+	if err := ToGo(nil, new(int)); err == nil || err.Error() != "cannot convert NULL to int" {
+		t.Errorf("incorrect error converting nil to int: %v", err)
+	}
+
+	errorTests := []struct {
+		obj      Object
+		target   interface{}
+		expected string
+	}{
+		{&String{Value: "1"}, new(int), "cannot convert STRING to int"},
+		{&Integer{Value: 300}, new(uint8), "cannot convert 300 to uint8: out of range"},
+		{&Integer{Value: -1}, new(uint), "cannot convert -1 to uint: out of range"},
+		{array, new([2]interface{}), "cannot convert ARRAY of length 3 to [2]interface {}"},
+		{hash, new(map[string]string), "cannot convert INTEGER to string"},
+		{&Integer{Value: 1}, 0, "cannot convert to int: target must be a non-nil pointer"},
+	}
+
+	for i, tt := range errorTests {
+		err := ToGo(tt.obj, tt.target)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("Test #%d: incorrect error. Expected %q, received %v", i, tt.expected, err)
+		}
+	}
+}
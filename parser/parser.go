@@ -23,18 +23,32 @@ const ( //order or precedence
 )
 
 var precedences = map[token.TokenType]int{
-	token.ASSIGN:   ASSIGN,
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       COMPARATOR,
-	token.GT:       COMPARATOR,
-	token.PLUS:     ADD,
-	token.MINUS:    ADD,
-	token.ASTERISK: MULT,
-	token.SLASH:    MULT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
-	token.PERIOD:   INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              COMPARATOR,
+	token.GT:              COMPARATOR,
+	token.PLUS:            ADD,
+	token.MINUS:           ADD,
+	token.ASTERISK:        MULT,
+	token.SLASH:           MULT,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+	token.PERIOD:          INDEX,
+}
+
+//statementKeywords start a new statement; error recovery resumes parsing at them
+var statementKeywords = map[token.TokenType]bool{
+	token.LET:      true,
+	token.RETURN:   true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
 }
 
 type Parser struct {
@@ -43,7 +57,12 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 
-	errors []string
+	errors    []string
+	panicking bool //set after an error, cleared once the parser has resynchronized
+
+	nesting      int //number of currently open 'please'/'{' tokens
+	blockNesting int //nesting of the innermost block being parsed
+	loopDepth    int //number of enclosing loops in the current function, for break/continue
 
 	prefixFunctions map[token.TokenType]prefixParseFn
 	infixFunctions  map[token.TokenType]infixParseFn
@@ -60,6 +79,7 @@ func NewParser(l *lexer.Lexer) *Parser {
 
 	p.prefixFunctions = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.EXCLAMATION, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
@@ -67,6 +87,7 @@ func NewParser(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parsedGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
@@ -83,7 +104,11 @@ func NewParser(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
-	p.registerInfix(token.ASSIGN, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.PERIOD, p.parsePeriodIndexExpression)
 
 	return p
@@ -92,6 +117,15 @@ func NewParser(l *lexer.Lexer) *Parser {
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.nesting++
+	case token.RBRACE:
+		if p.nesting > 0 {
+			p.nesting--
+		}
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -100,7 +134,9 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	for p.curToken.Type != token.EOF {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.panicking {
+			p.synchronize() //drop the half-built statement
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
@@ -109,24 +145,78 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+//synchronize skips tokens after a syntax error until the end of the broken statement:
+//a 'plz', or just before a statement keyword or the 'thanks' closing the current block
+func (p *Parser) synchronize() {
+	p.panicking = false
+
+	for !p.curTokenIs(token.EOF) {
+		if p.nesting == p.blockNesting {
+			if p.curTokenIs(token.TERMINATOR) {
+				return
+			}
+			if p.peekTokenIs(token.EOF) || statementKeywords[p.peekToken.Type] {
+				return
+			}
+			if p.blockNesting > 0 && p.peekTokenIs(token.RBRACE) {
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
+
 func (p *Parser) Errors() []string {
 	return p.errors
 }
 
 func (p *Parser) addError(t token.TokenType) {
-	msg := fmt.Sprintf("Unexpected token type. Expected: %s, received %s", t, p.peekToken.Type)
+	p.errorAt(p.peekToken.Pos, "expected %s, found %s", token.Describe(t), p.peekToken.Describe())
+}
+
+//errorAt records a parser error prefixed with its source position and enters panic mode.
+//Errors raised while already panicking are follow-on noise and are dropped
+func (p *Parser) errorAt(pos token.Position, format string, a ...interface{}) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+
+	msg := fmt.Sprintf(format, a...)
+	if pos.IsValid() {
+		msg = pos.String() + ": " + msg
+	}
 	p.errors = append(p.errors, msg)
 }
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if stmt := p.parseReturnStatement(); stmt != nil {
+			return stmt
+		}
+	case token.WHILE:
+		if stmt := p.parseWhileStatement(); stmt != nil {
+			return stmt
+		}
+	case token.FOR:
+		if stmt := p.parseForStatement(); stmt != nil {
+			return stmt
+		}
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
+	case token.RBRACE:
+		p.errorAt(p.curToken.Pos, "found %s with no matching 'please'", p.curToken.Describe())
 	default:
-		return p.parseExpressionStatement()
+		if stmt := p.parseExpressionStatement(); stmt != nil {
+			return stmt
+		}
 	}
+	return nil
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
@@ -136,9 +226,11 @@ func (p *Parser) parseIdentifier() ast.Expression {
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) { //expectPeek calls p.nextToken() so the p.curToken above is not the same as the p.curToken below
+	if !p.peekTokenIs(token.IDENT) {
+		p.errorAt(p.peekToken.Pos, "expected variable name after 'let', found %s", p.peekToken.Describe())
 		return nil
 	}
+	p.nextToken() //the p.curToken above is not the same as the p.curToken below
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
@@ -149,10 +241,14 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.nextToken()
 
 	stmt.Value = p.parseExpression(LOWEST)
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && fn.Name == "" {
+		fn.Name = stmt.Name.Value //named after its binding, for stack traces
+	}
 
 	if p.peekTokenIs(token.TERMINATOR) {
 		p.nextToken()
 	}
+	stmt.EndPos = p.curToken.End
 
 	return stmt
 }
@@ -162,8 +258,21 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("Could not parse %q as int", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorAt(p.curToken.Pos, "Could not parse %q as int", p.curToken.Literal)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.errorAt(p.curToken.Pos, "Could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 
@@ -179,6 +288,9 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 }
 
 func (p *Parser) expectPeek(t token.TokenType) bool {
+	if p.panicking {
+		return false //an earlier part of this statement is already broken
+	}
 	if p.peekToken.Type == t {
 		p.nextToken()
 		return true
@@ -206,6 +318,7 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	if p.peekTokenIs(token.TERMINATOR) {
 		p.nextToken()
 	}
+	stmt.EndPos = p.curToken.End
 
 	return stmt
 }
@@ -226,6 +339,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	if p.peekTokenIs(token.TERMINATOR) {
 		p.nextToken()
 	}
+	stmt.EndPos = p.curToken.End
 
 	return stmt
 }
@@ -239,6 +353,7 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	p.nextToken()
 
 	expression.Right = p.parseExpression(PREFIX)
+	expression.EndPos = p.curToken.End
 
 	return expression
 }
@@ -253,6 +368,28 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	prec := p.curPrecedence()
 	p.nextToken()
 	expression.Right = p.parseExpression(prec)
+	expression.EndPos = p.curToken.End
+
+	return expression
+}
+
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Target:   target,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.errorAt(target.Pos(), "cannot assign to %s", target.String())
+		return nil
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1) //right associative, so a be b be 1 assigns both
+	expression.EndPos = p.curToken.End
 
 	return expression
 }
@@ -264,6 +401,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		return nil
 	}
 	leftExp := prefix()
+	if p.panicking {
+		return nil
+	}
 
 	for !p.peekTokenIs(token.TERMINATOR) && precedence < p.peekPrecendence() {
 		infix := p.infixFunctions[p.peekToken.Type]
@@ -273,6 +413,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 		p.nextToken()
 		leftExp = infix(leftExp)
+		if p.panicking {
+			return nil
+		}
 	}
 
 	return leftExp
@@ -295,7 +438,7 @@ func (p *Parser) curPrecedence() int {
 }
 
 func (p *Parser) throwNoParserError(t token.TokenType) {
-	p.errors = append(p.errors, fmt.Sprintf("No prefix parse function for %s", t))
+	p.errorAt(p.curToken.Pos, "expected an expression, found %s", p.curToken.Describe())
 }
 
 func (p *Parser) parsedGroupedExpression() ast.Expression {
@@ -346,6 +489,42 @@ func (p *Parser) parseIfExpression() ast.Expression {
 
 		expression.Alternative = p.parseBlockStatement()
 	}
+	expression.EndPos = p.curToken.End
+
+	return expression
+}
+
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Body = p.parseBlockStatement()
+
+	if !p.expectPeek(token.SORRY) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		if !p.peekTokenIs(token.IDENT) {
+			p.errorAt(p.peekToken.Pos, "expected error variable name after 'sorry (', found %s", p.peekToken.Describe())
+			return nil
+		}
+		p.nextToken()
+		expression.Parameter = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Handler = p.parseBlockStatement()
+	expression.EndPos = p.curToken.End
 
 	return expression
 }
@@ -354,19 +533,112 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
+	outer := p.blockNesting
+	p.blockNesting = p.nesting
+	defer func() { p.blockNesting = outer }()
+
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.panicking {
+			p.synchronize()
+		} else if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
 
+	if p.curTokenIs(token.EOF) {
+		p.errorAt(p.curToken.Pos, "expected 'thanks' to close block opened with '%s' at line %d", block.Token.Literal, block.Token.Pos.Line)
+		return nil
+	}
+	block.EndPos = p.curToken.End
+
 	return block
 }
 
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+	if p.peekTokenIs(token.TERMINATOR) {
+		p.nextToken()
+	}
+	stmt.EndPos = p.curToken.End
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.peekTokenIs(token.IDENT) {
+		p.errorAt(p.peekToken.Pos, "expected loop variable after 'for', found %s", p.peekToken.Describe())
+		return nil
+	}
+	p.nextToken()
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+	if p.peekTokenIs(token.TERMINATOR) {
+		p.nextToken()
+	}
+	stmt.EndPos = p.curToken.End
+
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	tok := p.curToken
+	if p.loopDepth == 0 {
+		p.errorAt(tok.Pos, "%s outside of a loop", tok.Describe())
+		return nil
+	}
+
+	if p.peekTokenIs(token.TERMINATOR) {
+		p.nextToken()
+	}
+
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok, EndPos: p.curToken.End}
+	}
+	return &ast.ContinueStatement{Token: tok, EndPos: p.curToken.End}
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
@@ -374,48 +646,109 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	outerLoops := p.loopDepth
+	p.loopDepth = 0 //a function body can't break out of the loop it was defined in
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = outerLoops
+	lit.EndPos = p.curToken.End
 
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+//parseFunctionParameters fills in the parameters of lit: plain names, then names with
+//a default value, then at most one rest parameter
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
+	lit.Defaults = []ast.Expression{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectParameter() {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.checkNewParameter(lit, lit.Rest) {
+				return false
+			}
+			if p.peekTokenIs(token.COMMA) {
+				p.errorAt(p.peekToken.Pos, "rest parameter %s must be the last parameter", lit.Rest.Value)
+				return false
+			}
+			break
+		}
+
+		if !p.expectParameter() {
+			return false
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.checkNewParameter(lit, ident) {
+			return false
+		}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+		var value ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			value = p.parseExpression(LOWEST)
+			if value == nil {
+				return false
+			}
+		} else if len(lit.Defaults) > 0 && lit.Defaults[len(lit.Defaults)-1] != nil {
+			p.errorAt(ident.Token.Pos, "parameter %s needs a default value because it follows one that has one", ident.Value)
+			return false
+		}
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
+		lit.Parameters = append(lit.Parameters, ident)
+		lit.Defaults = append(lit.Defaults, value)
 
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+	return p.expectPeek(token.RPAREN)
+}
+
+//checkNewParameter reports an error if lit already has a parameter named like ident, since
+//the engines would disagree about which argument it gets
+func (p *Parser) checkNewParameter(lit *ast.FunctionLiteral, ident *ast.Identifier) bool {
+	for _, param := range lit.Parameters {
+		if param.Value == ident.Value {
+			p.errorAt(ident.Token.Pos, "duplicate parameter %s", ident.Value)
+			return false
+		}
 	}
+	return true
+}
 
-	return identifiers
+func (p *Parser) expectParameter() bool {
+	if !p.peekTokenIs(token.IDENT) {
+		p.errorAt(p.peekToken.Pos, "expected parameter name, found %s", p.peekToken.Describe())
+		return false
+	}
+	p.nextToken()
+	return true
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.EndPos = p.curToken.End
 	return exp
 }
 
@@ -423,6 +756,7 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.EndPos = p.curToken.End
 
 	return array
 }
@@ -438,7 +772,7 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	p.nextToken()
 	list = append(list, p.parseExpression(LOWEST))
 
-	for p.peekTokenIs(token.COMMA) {
+	for p.peekTokenIs(token.COMMA) && !p.panicking {
 		p.nextToken()
 		p.nextToken()
 		list = append(list, p.parseExpression(LOWEST))
@@ -461,6 +795,7 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	ex.EndPos = p.curToken.End
 
 	return ex
 }
@@ -468,26 +803,26 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 func (p *Parser) parsePeriodIndexExpression(left ast.Expression) ast.Expression {
 	ex := &ast.IndexExpression{Token: p.curToken, Left: left}
 
-	p.nextToken()
-	ie := p.parseExpression(LOWEST)
-	id, ok := ie.(*ast.Identifier)
-	if !ok {
+	if !p.peekTokenIs(token.IDENT) {
+		p.errorAt(p.peekToken.Pos, "expected field name after '.', found %s", p.peekToken.Describe())
 		return nil
 	}
+	p.nextToken()
 
 	lit := &ast.StringLiteral{Token: p.curToken}
-	lit.Value = id.String()
+	lit.Value = p.curToken.Literal
 
 	ex.Index = lit
+	ex.EndPos = p.curToken.End
 
 	return ex
 }
 
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash.Pairs = []ast.HashLiteralPair{}
 
-	for !p.peekTokenIs(token.RBRACE) {
+	for !p.peekTokenIs(token.RBRACE) && !p.panicking {
 		p.nextToken()
 		key := p.parseExpression(LOWEST)
 
@@ -499,7 +834,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 
 		val := p.parseExpression(LOWEST)
 
-		hash.Pairs[key] = val
+		hash.Pairs = append(hash.Pairs, ast.HashLiteralPair{Key: key, Value: val})
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -509,6 +844,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.EndPos = p.curToken.End
 
 	return hash
 }
@@ -637,22 +637,28 @@ func TestParsingHashLiteralStringKeys(t *testing.T) {
 		t.Fatalf("incorrect number of hashset pairs. Expected 4, got %d", len(h.Pairs))
 	}
 
-	expected := map[string]int64{
-		"one":   1,
-		"two":   2,
-		"three": 3,
-		"four":  4,
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
+		{"four", 4},
 	}
 
-	for key, val := range h.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for i, pair := range h.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not StringLiteral")
+			continue
 		}
 
-		expectedValue := expected[literal.String()]
+		if literal.String() != expected[i].key {
+			t.Errorf("Pair %d out of order. Expected key %s, received %s", i, expected[i].key, literal.String())
+		}
 
-		testIntegerLiteral(t, val, expectedValue)
+		testIntegerLiteral(t, pair.Value, expected[i].value)
 	}
 }
 
@@ -674,3 +680,459 @@ func TestParsingEmptyHashLiteral(t *testing.T) {
 		t.Fatalf("incorrect number of hashset pairs. Expected 0, got %d", len(h.Pairs))
 	}
 }
+
+func TestParserErrorPositions(t *testing.T) {
+	input := "let a be 1 plz\nlet be 2 plz"
+
+	l := lexer.NewFileLexer("script.plz", input)
+	p := NewParser(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("Expected parser errors, received none")
+	}
+
+	expected := "script.plz:2:5: expected variable name after 'let', found 'be'"
+	if errors[0] != expected {
+		t.Errorf("Incorrect error. Expected %q, received %q", expected, errors[0])
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "let a be 1 plz\n  add(a, 2) plz"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[1].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement not *ast.ExpressionStatement, is %T", program.Statements[1])
+	}
+
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("expression not *ast.CallExpression, is %T", stmt.Expression)
+	}
+
+	if call.Pos().String() != "2:3" {
+		t.Errorf("Incorrect call start. Expected 2:3, received %s", call.Pos())
+	}
+
+	if call.End().String() != "2:12" {
+		t.Errorf("Incorrect call end. Expected 2:12, received %s", call.End())
+	}
+
+	if stmt.End().String() != "2:16" {
+		t.Errorf("Incorrect statement end. Expected 2:16, received %s", stmt.End())
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let be 1 plz\nlet y be plz\nlet z be 3 plz",
+			[]string{
+				"1:5: expected variable name after 'let', found 'be'",
+				"2:10: expected an expression, found 'plz'",
+			},
+		},
+		{
+			"if (x please\n  let a be 1 plz\nthanks\nlet b be (2 plz\nb plz",
+			[]string{
+				"1:7: expected ')', found 'please'",
+				"4:13: expected ')', found 'plz'",
+			},
+		},
+		{
+			"let f be function(x) please\n  let y be * 2 plz\n  return y plz\nthanks plz\nf(1, ) plz",
+			[]string{
+				"2:12: expected an expression, found '*'",
+				"5:6: expected an expression, found ')'",
+			},
+		},
+		{
+			"let f be function(x) please\n  if (x) please\n    return 1 plz\nthanks plz",
+			[]string{
+				"4:11: expected 'thanks' to close block opened with 'please' at line 1",
+			},
+		},
+		{
+			"thanks\nlet a be 1 plz\nfunction(1) please thanks",
+			[]string{
+				"1:1: found 'thanks' with no matching 'please'",
+				"3:10: expected parameter name, found integer '1'",
+			},
+		},
+		{
+			"let a be 1 plz // fine\nlet b be /* not closed",
+			[]string{
+				"2:10: expected an expression, found unterminated comment",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("Incorrect number of errors for %q. Expected %d, received %d: %q", tt.input, len(tt.expected), len(errors), errors)
+			continue
+		}
+
+		for i, msg := range tt.expected {
+			if errors[i] != msg {
+				t.Errorf("Incorrect error #%d. Expected %q, received %q", i+1, msg, errors[i])
+			}
+		}
+	}
+}
+
+func TestParserRecoveryKeepsValidStatements(t *testing.T) {
+	input := `
+	let a be 1 plz
+	let be 2 plz
+	let c be 3 plz
+	`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("Expected 1 parser error, received %d: %q", len(p.Errors()), p.Errors())
+	}
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Incorrect number of statements. Expected 2, received %d", len(program.Statements))
+	}
+
+	testLetStatement(t, program.Statements[0], "a")
+	testLetStatement(t, program.Statements[1], "c")
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14 plz", 3.14},
+		{"1e3 plz", 1000},
+		{"2.5e-1 plz", 0.25},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("statement not *ast.ExpressionStatement, is %T", program.Statements[0])
+		}
+
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("expression not *ast.FloatLiteral, is %T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value incorrect. Expected %g, received %g", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) please let x be x + 1 plz thanks`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Program has incorrect number of statements. Got %d, expected 1", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("statement not *ast.WhileStatement, is %T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("Body length is not 1, got %d", len(stmt.Body.Statements))
+	}
+	testLetStatement(t, stmt.Body.Statements[0], "x")
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for item in items please if (item) please break plz thanks continue thanks`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("statement not *ast.ForStatement, is %T", program.Statements[0])
+	}
+
+	if stmt.Variable.Value != "item" {
+		t.Errorf("Incorrect loop variable. Expected item, got %s", stmt.Variable.Value)
+	}
+
+	if !testIdentifier(t, stmt.Iterable, "items") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("Body length is not 2, got %d", len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("second statement not *ast.ContinueStatement, is %T", stmt.Body.Statements[1])
+	}
+}
+
+func TestLoopTerminator(t *testing.T) {
+	tests := []struct {
+		input string
+		end   string
+	}{
+		{"while (False) please thanks plz 1", "1:32"},
+		{"for x in [] please thanks plz 1", "1:30"},
+		{"while (False) please thanks 1", "1:28"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 2 {
+			t.Fatalf("Program %q has incorrect number of statements. Got %d, expected 2", tt.input, len(program.Statements))
+		}
+		if end := program.Statements[0].End().String(); end != tt.end {
+			t.Errorf("Loop in %q ends at %s, expected %s", tt.input, end, tt.end)
+		}
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break plz", "1:1: 'break' outside of a loop"},
+		{"if (x) please continue thanks", "1:15: 'continue' outside of a loop"},
+		{"while (x) please let f be function() please break thanks plz thanks", "1:45: 'break' outside of a loop"},
+		{"for in xs please thanks", "1:5: expected loop variable after 'for', found 'in'"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("Expected 1 error for %q, received %q", tt.input, errors)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("Incorrect error. Expected %q, received %q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x be x + 1 plz", "x be (x+1)"},
+		{"x += 2 plz", "x += 2"},
+		{"arr[0] *= 3 plz", "(arr[0]) *= 3"},
+		{"m.count -= 1 plz", "(m[count]) -= 1"},
+		{"a be b be 1 plz", "a be b be 1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("statement not *ast.ExpressionStatement, is %T", program.Statements[0])
+		}
+
+		if _, ok := stmt.Expression.(*ast.AssignExpression); !ok {
+			t.Fatalf("expression not *ast.AssignExpression, is %T", stmt.Expression)
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("Incorrect expression. Expected %q, received %q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+	l := lexer.NewLexer("1 + x be 2 plz")
+	p := NewParser(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != "1:1: cannot assign to (1+x)" {
+		t.Errorf("Incorrect errors. Received %q", errors)
+	}
+}
+
+func TestFunctionLiteralName(t *testing.T) {
+	input := `let add be function(a, b) please a + b thanks plz function() please 1 thanks`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	if fn := let.Value.(*ast.FunctionLiteral); fn.Name != "add" {
+		t.Errorf("Function bound by let has wrong name. Expected add, received %q", fn.Name)
+	}
+
+	anonymous := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if anonymous.Name != "" {
+		t.Errorf("Anonymous function has a name: %q", anonymous.Name)
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input     string
+		parameter string
+		expected  string
+	}{
+		{"try please x thanks sorry (err) please err thanks", "err", "try x sorry (err) err"},
+		{"try please x thanks sorry please 0 thanks", "", "try x sorry 0"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("expression not *ast.TryExpression, is %T", stmt.Expression)
+		}
+
+		if tt.parameter == "" && exp.Parameter != nil {
+			t.Errorf("Unexpected parameter %s", exp.Parameter)
+		}
+		if tt.parameter != "" && (exp.Parameter == nil || exp.Parameter.Value != tt.parameter) {
+			t.Errorf("Incorrect parameter. Expected %s, received %+v", tt.parameter, exp.Parameter)
+		}
+		if exp.String() != tt.expected {
+			t.Errorf("Incorrect string. Expected %q, received %q", tt.expected, exp.String())
+		}
+	}
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try please x thanks", "1:20: expected 'sorry', found end of input"},
+		{"try please x thanks sorry (1) please thanks", "1:28: expected error variable name after 'sorry (', found integer '1'"},
+		{"try x", "1:5: expected 'please', found identifier 'x'"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("Expected 1 error for %q, received %q", tt.input, errors)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("Incorrect error. Expected %q, received %q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"function(x, y be 10) please x thanks", "function(x, y be 10) x"},
+		{"function(x be 1 + 2, ...rest) please x thanks", "function(x be (1+2), ...rest) x"},
+		{"function(...all) please all thanks", "function(...all) all"},
+		{"function() please 1 thanks", "function() 1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		fn, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression not *ast.FunctionLiteral, is %T", stmt.Expression)
+		}
+		if fn.String() != tt.expected {
+			t.Errorf("Incorrect string. Expected %q, received %q", tt.expected, fn.String())
+		}
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"function(x be 1, y) please x thanks", "1:18: parameter y needs a default value because it follows one that has one"},
+		{"function(...rest, x) please x thanks", "1:17: rest parameter rest must be the last parameter"},
+		{"function(...1) please 1 thanks", "1:13: expected parameter name, found integer '1'"},
+		{"function(a, a) please a thanks", "1:13: duplicate parameter a"},
+		{"function(a, b be 1, ...a) please a thanks", "1:24: duplicate parameter a"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("Expected an error for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("Incorrect error. Expected %q, received %q", tt.expected, errors[0])
+		}
+	}
+}
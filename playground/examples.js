@@ -21,5 +21,19 @@ let sum be function(arr) please
         return arr[0] + sum(rest(arr)) plz 
     thanks
     
-print(sum(nums)) plz`
+print(sum(nums)) plz`,
+  loops: `let nums be [1, 2, 3, 4, 5] plz
+let total be 0 plz
+
+for n in nums please
+    let total be total + n plz
+thanks
+
+let i be 0 plz
+while (i < 3) please
+    print(i) plz
+    let i be i + 1 plz
+thanks
+
+print(total) plz`
 };
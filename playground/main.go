@@ -1,61 +1,46 @@
 package main
 
 import (
-	"bytes"
-	"io"
-	"log"
+	"context"
+	"encoding/json"
 	"net/http"
-	"os"
 
 	"github.com/MYKatz/PLZ/interpreter"
+	"github.com/MYKatz/PLZ/server"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
-type Event struct {
-	Code string `json:"code"`
-}
-
-type Response struct {
-	Res        string `json:"res"`
-	StatusCode int    `json:"statusCode"`
-}
+//playground runs the submitted code in the same sandbox as 'plz serve'; the page itself is
+//hosted separately
+var playground = server.New(server.DefaultOptions())
 
-func interpret(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-
-	resp := events.APIGatewayProxyResponse{Headers: make(map[string]string)}
-	resp.Headers["Access-Control-Allow-Origin"] = "*"
-	//capture output of stdout
-	old := os.Stdout
-	r, w, err := os.Pipe()
-	if err != nil {
-		log.Fatal(err)
+//interpret takes the code as JSON in a POST body, like the /run endpoint of 'plz serve', or
+//from the query string of a GET
+func interpret(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	headers := map[string]string{
+		"Access-Control-Allow-Origin": "*",
+		"Content-Type":                "application/json",
 	}
-	os.Stdout = w
-
-	outC := make(chan string)
 
-	go func() {
-		var buf bytes.Buffer
-		io.Copy(&buf, r)
-		outC <- buf.String()
-	}()
-
-	code := req.QueryStringParameters["code"]
-	interpreter.Interpret(code)
-
-	w.Close()
-	os.Stdout = old // restoring the real stdout
-	out := <-outC
+	run := server.RunRequest{Code: req.QueryStringParameters["code"]}
+	if req.HTTPMethod == http.MethodPost {
+		if err := json.Unmarshal([]byte(req.Body), &run); err != nil {
+			body, _ := json.Marshal(map[string]string{"message": "invalid request: " + err.Error()})
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Headers: headers, Body: string(body)}, nil
+		}
+	}
 
-	headers := make(map[string]string)
-	headers["Access-Control-Allow-Origin"] = "*"
+	engine := interpreter.EngineEval
+	if run.Engine == string(interpreter.EngineVM) {
+		engine = interpreter.EngineVM
+	}
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusOK,
-		Headers:    headers,
-		Body:       string(out),
-	}, nil
+	body, err := json.Marshal(playground.Run(ctx, run.Code, engine))
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Headers: headers, Body: string(body)}, nil
 }
 
 func main() {
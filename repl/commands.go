@@ -0,0 +1,189 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/MYKatz/PLZ/ast"
+	"github.com/MYKatz/PLZ/evaluator"
+	"github.com/MYKatz/PLZ/lexer"
+	"github.com/MYKatz/PLZ/object"
+	"github.com/MYKatz/PLZ/parser"
+)
+
+type command struct {
+	usage string
+	help  string
+	run   func(s *session, arg string)
+}
+
+var commands map[string]command
+
+func init() { //assigned in init because :help refers back to the table
+	commands = map[string]command{
+		"env":   {":env", "list the bindings in this session", (*session).listEnv},
+		"reset": {":reset", "forget every binding and start a fresh session", (*session).reset},
+		"load":  {":load file.plz", "run a file in this session", (*session).load},
+		"save":  {":save session.plz", "write the code entered so far to a file", (*session).save},
+		"type":  {":type expr", "show the type of an expression without assignments, calls or loops", (*session).showType},
+		"help":  {":help", "list the REPL commands", (*session).help},
+	}
+}
+
+//runCommand dispatches a line starting with ':'
+func (s *session) runCommand(line string) {
+	name, arg := line[1:], ""
+	if i := strings.IndexAny(name, " \t"); i >= 0 {
+		name, arg = name[:i], strings.TrimSpace(name[i+1:])
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.w, "Unknown command :%s, try :help\n", name)
+		return
+	}
+	cmd.run(s, arg)
+}
+
+func (s *session) listEnv(arg string) {
+	for _, name := range s.env.Names() {
+		val, _ := s.env.Get(name)
+		fmt.Fprintf(s.w, "%s = %s\n", name, val.Inspect())
+	}
+}
+
+func (s *session) reset(arg string) {
+	s.env = s.newEnvironment()
+	s.history = nil
+	io.WriteString(s.w, "Session reset\n")
+}
+
+func (s *session) load(arg string) {
+	if arg == "" {
+		io.WriteString(s.w, "Usage: :load file.plz\n")
+		return
+	}
+
+	src, err := ioutil.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintf(s.w, "Could not load %s: %s\n", arg, err)
+		return
+	}
+
+	if s.eval(arg, string(src)) {
+		s.history = append(s.history, string(src))
+	}
+}
+
+func (s *session) save(arg string) {
+	if arg == "" {
+		io.WriteString(s.w, "Usage: :save session.plz\n")
+		return
+	}
+
+	src := strings.Join(s.history, "\n")
+	if len(s.history) > 0 {
+		src += "\n"
+	}
+	if err := ioutil.WriteFile(arg, []byte(src), 0644); err != nil {
+		fmt.Fprintf(s.w, "Could not save %s: %s\n", arg, err)
+		return
+	}
+	fmt.Fprintf(s.w, "Saved %d entries to %s\n", len(s.history), arg)
+}
+
+func (s *session) showType(arg string) {
+	if arg == "" {
+		io.WriteString(s.w, "Usage: :type expr\n")
+		return
+	}
+
+	p := parser.NewParser(lexer.NewLexer(arg))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.w, p.Errors())
+		return
+	}
+
+	//assignments reach out of a child scope, calls can do anything and loops may never end, so
+	//only run expressions without them. Evaluate in a child scope so lets don't leak either
+	if node := sideEffect(prog); node != nil {
+		fmt.Fprintf(s.w, "%s: :type can't run assignments, function calls or loops\n", node.Pos())
+		return
+	}
+	evaluated := evaluator.Eval(prog, object.NewEnclosedEnvironment(s.env))
+	if evaluated == nil {
+		io.WriteString(s.w, object.NULL_OBJ+"\n")
+		return
+	}
+	if ho, ok := evaluated.(*object.HashObject); ok {
+		evaluated = ho.Inner
+	}
+	if err, ok := evaluated.(*object.Error); ok {
+		io.WriteString(s.w, err.Traceback()+"\n")
+		return
+	}
+	io.WriteString(s.w, evaluated.Type()+"\n")
+}
+
+//sideEffect returns the first assignment, call or loop in node that running it would reach, or
+//nil. Function bodies aren't checked, since defining a function doesn't run it
+func sideEffect(node ast.Node) ast.Node {
+	var children []ast.Node
+	switch node := node.(type) {
+	case *ast.AssignExpression, *ast.CallExpression, *ast.WhileStatement, *ast.ForStatement:
+		return node
+	case *ast.Program:
+		for _, stmt := range node.Statements {
+			children = append(children, stmt)
+		}
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			children = append(children, stmt)
+		}
+	case *ast.LetStatement:
+		children = append(children, node.Value)
+	case *ast.ReturnStatement:
+		children = append(children, node.Value)
+	case *ast.ExpressionStatement:
+		children = append(children, node.Expression)
+	case *ast.PrefixExpression:
+		children = append(children, node.Right)
+	case *ast.InfixExpression:
+		children = append(children, node.Left, node.Right)
+	case *ast.IfExpression:
+		children = append(children, node.Condition, node.Consequence, node.Alternative)
+	case *ast.TryExpression:
+		children = append(children, node.Body, node.Handler)
+	case *ast.IndexExpression:
+		children = append(children, node.Left, node.Index)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			children = append(children, el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			children = append(children, pair.Key, pair.Value)
+		}
+	}
+
+	for _, child := range children {
+		if child == nil || reflect.ValueOf(child).IsNil() {
+			continue
+		}
+		if found := sideEffect(child); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func (s *session) help(arg string) {
+	for _, name := range []string{"env", "reset", "load", "save", "type", "help"} {
+		cmd := commands[name]
+		fmt.Fprintf(s.w, "  %-20s %s\n", cmd.usage, cmd.help)
+	}
+}
@@ -2,46 +2,111 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
 	"io"
+	"strings"
 
 	"github.com/MYKatz/PLZ/evaluator"
 	"github.com/MYKatz/PLZ/lexer"
 	"github.com/MYKatz/PLZ/object"
 	"github.com/MYKatz/PLZ/parser"
+	"github.com/MYKatz/PLZ/token"
 )
 
 const prompt = ">>>"
+const continuationPrompt = "..."
+
+//session holds the state shared by every line typed into one REPL
+type session struct {
+	w       io.Writer
+	in      *bufio.Reader //shared with the input builtin, so scripts read the lines typed after them
+	env     *object.Environment
+	history []string //inputs that evaluated without error, replayed by :save
+}
 
 func Start(r io.Reader, w io.Writer) {
-	scanner := bufio.NewScanner(r)
+	s := &session{w: w, in: bufio.NewReader(r)}
+	s.env = s.newEnvironment()
+
+	var pending []string //lines of an input that still has open blocks
 
 	for { //input while(true) loop
-		fmt.Printf(prompt)
-		scanned := scanner.Scan()
-		if !scanned {
+		if len(pending) == 0 {
+			io.WriteString(w, prompt)
+		} else {
+			io.WriteString(w, continuationPrompt)
+		}
+		line, err := s.in.ReadString('\n')
+		if err != nil && line == "" {
 			return
 		} else {
-			line := scanner.Text()
-			l := lexer.NewLexer(line)
-			p := parser.NewParser(l)
-
-			prog := p.ParseProgram()
-
-			env := object.NewEnvironment()
+			line = strings.TrimRight(line, "\r\n")
+			if len(pending) == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+				s.runCommand(strings.TrimSpace(line))
+				continue
+			}
 
-			if len(p.Errors()) != 0 {
-				printParserErrors(w, p.Errors())
+			pending = append(pending, line)
+			src := strings.Join(pending, "\n")
+			if !isComplete(src) {
 				continue
 			}
+			pending = nil
+
+			if s.eval("", src) {
+				s.history = append(s.history, src)
+			}
+		}
+	}
+}
+
+//isComplete reports whether every 'please', '(', '[', '{' and '/*' in src has been closed
+func isComplete(src string) bool {
+	l := lexer.NewLexer(src)
+	depth := 0
+	tries := 0 //try blocks still waiting for their sorry
 
-			evaluated := evaluator.Eval(prog, env)
-			if evaluated != nil {
-				io.WriteString(w, evaluated.Inspect())
-				io.WriteString(w, "\n")
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LBRACE, token.LPAREN, token.LBRACKET:
+			depth++
+		case token.RBRACE, token.RPAREN, token.RBRACKET:
+			depth--
+		case token.TRY:
+			tries++
+		case token.SORRY:
+			tries--
+		case token.ILLEGAL:
+			if tok.Literal == "/*" {
+				return false //block comment still open
 			}
 		}
 	}
+
+	return depth <= 0 && tries <= 0 //too many closers is a syntax error for the parser to report
+}
+
+//eval runs src in the session environment and prints the result, reporting whether it succeeded
+func (s *session) eval(filename string, src string) bool {
+	l := lexer.NewFileLexer(filename, src)
+	p := parser.NewParser(l)
+
+	prog := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.w, p.Errors())
+		return false
+	}
+
+	evaluated := evaluator.Eval(prog, s.env)
+	if err, ok := evaluated.(*object.Error); ok {
+		io.WriteString(s.w, err.Traceback())
+		io.WriteString(s.w, "\n")
+	} else if evaluated != nil {
+		io.WriteString(s.w, evaluated.Inspect())
+		io.WriteString(s.w, "\n")
+	}
+
+	return evaluated == nil || evaluated.Type() != object.ERROR_OBJ
 }
 
 func printParserErrors(w io.Writer, errs []string) {
@@ -50,3 +115,12 @@ func printParserErrors(w io.Writer, errs []string) {
 		io.WriteString(w, "\t  "+e+"\n")
 	}
 }
+
+//newEnvironment creates a session environment whose call depth is bounded, so runaway
+//recursion reports an error instead of crashing the REPL
+func (s *session) newEnvironment() *object.Environment {
+	env := object.NewEnvironment()
+	env.SetBudget(object.NewBudget(object.DefaultLimits()))
+	env.SetBuiltins(evaluator.NewBuiltins(s.w, s.in, 0))
+	return env
+}
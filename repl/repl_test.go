@@ -0,0 +1,117 @@
+package repl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runRepl(input string) string {
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	return strings.Replace(out.String(), prompt, "", -1)
+}
+
+func TestBindingsPersistAcrossLines(t *testing.T) {
+	output := runRepl("let x be 5 plz\nlet add be function(a) please a + x thanks plz\nadd(2)\n")
+
+	if output != "7\n" {
+		t.Errorf("Incorrect output. Expected %q, received %q", "7\n", output)
+	}
+}
+
+func TestPrintAndInputUseTheSession(t *testing.T) {
+	output := runRepl("let name be input(\"name? \") plz\nMatt\nprint(\"hi \" + name) plz\n")
+
+	expected := "name? hi Matt\nNull\n" //print returns Null
+	if output != expected {
+		t.Errorf("Incorrect output. Expected %q, received %q", expected, output)
+	}
+}
+
+func TestMetaCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let b be True plz\nlet a be [1] plz\n:env\n", "a = [1]\nb = true\n"},
+		{"let a be 1 plz\n:type a + 1\n:type \"s\"\n", "INTEGER\nSTRING\n"},
+		{":type let y be 1 plz y\n:env\n", "INTEGER\n"},
+		{"let n be 1 plz\n:type n be 99\nn\n", "1:1: :type can't run assignments, function calls or loops\n1\n"},
+		{"let a be [1] plz\n:type [a[0] += 1]\n:type len(a)\na\n", "1:2: :type can't run assignments, function calls or loops\n1:1: :type can't run assignments, function calls or loops\n[1]\n"},
+		{":type function(x) please x be 1 thanks\n", "FUNCTION\n"},
+		{":type while (True) please 1 thanks\n:type let y be 1 plz for x in [y] please x thanks\n", "1:1: :type can't run assignments, function calls or loops\n1:16: :type can't run assignments, function calls or loops\n"},
+		{"let a be 1 plz\n:reset\na\n", "Session reset\n1:1: Error: identifier not found: a\n"},
+		{":nope\n", "Unknown command :nope, try :help\n"},
+	}
+
+	for _, tt := range tests {
+		output := runRepl(tt.input)
+		if output != tt.expected {
+			t.Errorf("Incorrect output for %q. Expected %q, received %q", tt.input, tt.expected, output)
+		}
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plz-repl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "session.plz")
+
+	runRepl("let x be 40 plz\nundefined plz\nlet y be x + 2 plz\n:save " + file + "\n")
+
+	saved, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(saved) != "let x be 40 plz\nlet y be x + 2 plz\n" {
+		t.Errorf("Incorrect saved session. Received %q", saved)
+	}
+
+	output := runRepl(":load " + file + "\ny\n")
+	if output != "42\n" {
+		t.Errorf("Incorrect output after load. Expected %q, received %q", "42\n", output)
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := "let fib be function(x) please\nif (x < 2) please\nreturn x plz\nthanks\nreturn fib(x - 1) + fib(x - 2) plz\nthanks plz\nfib(10)\n[1,\n2]\n"
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">>>" + strings.Repeat(continuationPrompt, 5) + ">>>55\n>>>...[1, 2]\n>>>"
+	if out.String() != expected {
+		t.Errorf("Incorrect output. Expected %q, received %q", expected, out.String())
+	}
+}
+
+func TestIsComplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x be 1 plz", true},
+		{"function(x) please", false},
+		{"function(x) please x thanks", true},
+		{"print(1,", false},
+		{"{\"a\": [1, 2", false},
+		{"thanks", true},
+		{"1 /* still", false},
+		{"1 // please", true},
+		{"try please complain(\"x\") thanks", false},
+		{"try please complain(\"x\") thanks\nsorry (e) please e thanks", true},
+	}
+
+	for _, tt := range tests {
+		if isComplete(tt.input) != tt.expected {
+			t.Errorf("isComplete(%q) incorrect. Expected %t", tt.input, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+//HTTP server for the PLZ playground: serves the playground page and runs the code it sends
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/MYKatz/PLZ/interpreter"
+	"github.com/MYKatz/PLZ/object"
+)
+
+//Options configures the server and the sandbox each program runs in
+type Options struct {
+	Engine      interpreter.Engine //the engine used when a request doesn't ask for one
+	Limits      object.Limits      //any Context is replaced by one bounded by Timeout
+	Timeout     time.Duration      //how long a program may run, no limit if 0
+	MaxCodeSize int64              //largest request body accepted in bytes, no limit if 0
+	MaxOutput   int                //output past this many bytes is dropped, no limit if 0
+
+	Static   http.FileSystem //holds the playground files; none are served if nil
+	Snippets Store           //where shared snippets are saved; sharing is off if nil
+}
+
+//DefaultOptions are the limits the hosted playground uses for untrusted code
+func DefaultOptions() Options {
+	return Options{
+		Engine:      interpreter.EngineEval,
+		Limits:      object.Limits{MaxSteps: 1000000, MaxDepth: 1000, MaxAllocation: 100000},
+		Timeout:     5 * time.Second,
+		MaxCodeSize: 64 << 10,
+		MaxOutput:   1 << 20,
+	}
+}
+
+//staticFiles are the files of the playground served by their path
+var staticFiles = map[string]string{
+	"/":            "index.html",
+	"/index.html":  "index.html",
+	"/examples.js": "examples.js",
+	"/favicon.png": "favicon.png",
+}
+
+//RunRequest is the JSON body POSTed to /run
+type RunRequest struct {
+	Code   string `json:"code"`
+	Engine string `json:"engine,omitempty"` //"eval" or "vm"; the server's default if empty
+}
+
+//RunResponse is the JSON reply from /run. Result holds the value of the last statement, if it
+//has one, cut short with ... past MaxOutput bytes, and Error is set when the program didn't
+//parse or raised an error
+type RunResponse struct {
+	Output    string    `json:"output"`
+	Truncated bool      `json:"truncated,omitempty"` //output went over the limit and was cut short
+	Result    string    `json:"result,omitempty"`
+	Error     *RunError `json:"error,omitempty"`
+}
+
+//RunError describes why a program failed
+type RunError struct {
+	Type      string `json:"type"` //SyntaxError, or the type of the error raised, e.g. TypeError
+	Message   string `json:"message"`
+	Traceback string `json:"traceback,omitempty"`
+}
+
+//Server is an http.Handler serving the playground page at / and running code POSTed to /run,
+//or to /stream to have its output sent as it is printed. With a Store it also saves and loads
+//shared snippets at /save and /load
+type Server struct {
+	opts Options
+	mux  *http.ServeMux
+	runs runs
+}
+
+func New(opts Options) *Server {
+	s := &Server{opts: opts, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/run", s.handleRun)
+	s.mux.HandleFunc("/stream", s.handleStream)
+	s.mux.HandleFunc("/cancel", s.handleCancel)
+	s.mux.HandleFunc("/save", s.handleSave)
+	s.mux.HandleFunc("/load", s.handleLoad)
+	s.mux.HandleFunc("/", s.handleStatic)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	req, engine, ok := s.readRunRequest(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Run(r.Context(), req.Code, engine))
+}
+
+//readRunRequest decodes the code POSTed to /run or /stream, replying with an error and
+//reporting false if the request is invalid
+func (s *Server) readRunRequest(w http.ResponseWriter, r *http.Request) (RunRequest, interpreter.Engine, bool) {
+	var req RunRequest
+	if !s.readJSON(w, r, &req, "run code") {
+		return req, "", false
+	}
+
+	engine := s.opts.Engine
+	if req.Engine != "" {
+		engine = interpreter.Engine(req.Engine)
+	}
+	if engine != interpreter.EngineEval && engine != interpreter.EngineVM {
+		writeJSONError(w, http.StatusBadRequest, "unknown engine: "+req.Engine)
+		return req, "", false
+	}
+	return req, engine, true
+}
+
+//readJSON decodes the body POSTed to do action into v, replying with an error and reporting
+//false if the request isn't a POST or its body isn't valid
+func (s *Server) readJSON(w http.ResponseWriter, r *http.Request, v interface{}, action string) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use POST to "+action)
+		return false
+	}
+
+	body := r.Body
+	if s.opts.MaxCodeSize > 0 {
+		body = http.MaxBytesReader(w, body, s.opts.MaxCodeSize)
+	}
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		if strings.Contains(err.Error(), "too large") {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "code is too long")
+			return false
+		}
+		writeJSONError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return false
+	}
+	return true
+}
+
+//Run runs code in a sandbox of its own: a fresh interpreter with the server's limits, no input
+//and output capped at MaxOutput. It stops early if ctx is cancelled
+func (s *Server) Run(ctx context.Context, code string, engine interpreter.Engine) *RunResponse {
+	var buf bytes.Buffer
+	out := &limitedWriter{w: &buf, max: s.opts.MaxOutput}
+
+	resp := &RunResponse{}
+	resp.Result, resp.Error = s.run(ctx, code, engine, out)
+	resp.Output, resp.Truncated = buf.String(), out.truncated
+	return resp
+}
+
+//run runs code in a sandbox writing to out, returning the value of its last statement or why
+//it failed
+func (s *Server) run(ctx context.Context, code string, engine interpreter.Engine, out io.Writer) (string, *RunError) {
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+	limits := s.opts.Limits
+	limits.Context = ctx
+
+	i := interpreter.New(interpreter.Options{
+		Engine:    engine,
+		Limits:    limits,
+		Stdout:    out,
+		Stdin:     strings.NewReader(""),
+		MaxOutput: s.opts.MaxOutput,
+	})
+	result, err := i.Run(code)
+
+	switch err := err.(type) {
+	case *interpreter.SyntaxError:
+		return "", &RunError{Type: "SyntaxError", Message: err.Error()}
+	case *interpreter.RuntimeError:
+		return "", &RunError{Type: err.Err.ErrorType(), Message: err.Err.Message, Traceback: err.Error()}
+	}
+	if result == nil {
+		return "", nil
+	}
+	text, truncated := object.InspectLimit(result, s.opts.MaxOutput)
+	if truncated {
+		text += "..."
+	}
+	return text, nil
+}
+
+func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
+	name, ok := staticFiles[r.URL.Path]
+	if !ok || s.opts.Static == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	f, err := s.opts.Static.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "could not read "+name, http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, path.Base(name), info.ModTime(), f)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+//limitedWriter passes on the first max bytes written to it and drops the rest, without failing
+//the writes, so a chatty program runs to completion but can't use up the server's memory
+type limitedWriter struct {
+	w          io.Writer
+	max        int
+	written    int
+	truncated  bool
+	onTruncate func() //called the first time output is dropped, if set
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := l.max - l.written; l.max > 0 && len(p) > room {
+		p = p[:room]
+		if !l.truncated && l.onTruncate != nil {
+			defer l.onTruncate()
+		}
+		l.truncated = true
+	}
+
+	if len(p) > 0 {
+		if _, err := l.w.Write(p); err != nil {
+			return 0, err
+		}
+		l.written += len(p)
+	}
+	return n, nil
+}
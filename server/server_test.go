@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MYKatz/PLZ/interpreter"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected RunResponse
+	}{
+		{`print("hi") plz 1 + 2`, RunResponse{Output: "hi\n", Result: "3"}},
+		{`let x be 1 plz`, RunResponse{}},
+		{`let x be plz`, RunResponse{Error: &RunError{Type: "SyntaxError", Message: "1:10: expected an expression, found 'plz'"}}},
+		{`print("before") plz 1 + True`, RunResponse{Output: "before\n", Error: &RunError{Type: "TypeError", Message: "type mismatch: INTEGER + BOOLEAN", Traceback: "1:21: Error: type mismatch: INTEGER + BOOLEAN"}}},
+		{`while (True) please 1 thanks`, RunResponse{Error: &RunError{Type: "LimitError", Message: "step limit exceeded: more than 1000 steps"}}},
+		{`input()`, RunResponse{Result: "Null"}},
+		{`print("0123456789") plz print("0123456789")`, RunResponse{Output: "0123456789\n01234", Truncated: true, Result: "Null"}},
+		{`[1, 2, 3, 4, 5, 6, 7, 8]`, RunResponse{Result: "[1, 2, 3, 4, 5, ..."}},
+		{`print([1, 2, 3, 4, 5, 6, 7, 8])`, RunResponse{Output: "[1, 2, 3, 4, 5, ", Truncated: true, Result: "Null"}},
+	}
+
+	opts := DefaultOptions()
+	opts.Limits.MaxSteps = 1000
+	opts.MaxOutput = 16
+	s := New(opts)
+
+	for _, engine := range []interpreter.Engine{interpreter.EngineEval, interpreter.EngineVM} {
+		for _, tt := range tests {
+			body := postRun(t, s, `{"code": `+quote(tt.code)+`, "engine": "`+string(engine)+`"}`, http.StatusOK)
+
+			var resp RunResponse
+			if err := json.Unmarshal([]byte(body), &resp); err != nil {
+				t.Fatalf("invalid response %q: %s", body, err)
+			}
+			if resp.Error != nil && tt.expected.Error != nil && tt.expected.Error.Traceback == "" {
+				resp.Error.Traceback = "" //where the engines stop differs
+			}
+			if describeRun(resp) != describeRun(tt.expected) {
+				t.Errorf("Incorrect response for %q with engine %s. Expected %s, received %s", tt.code, engine, describeRun(tt.expected), describeRun(resp))
+			}
+		}
+	}
+}
+
+func TestRunCyclicValues(t *testing.T) {
+	s := New(DefaultOptions())
+
+	//printing a value that holds itself must not bring the server down
+	for _, engine := range []interpreter.Engine{interpreter.EngineEval, interpreter.EngineVM} {
+		tests := []struct {
+			code     string
+			expected RunResponse
+		}{
+			{`let a be [0] plz a[0] be a plz a`, RunResponse{Result: "[[...]]"}},
+			{`let h be {} plz h["x"] be h plz print(h)`, RunResponse{Output: "{x:{...},}\n", Result: "Null"}},
+			{`1 + 1`, RunResponse{Result: "2"}},
+		}
+
+		for _, tt := range tests {
+			body := postRun(t, s, `{"code": `+quote(tt.code)+`, "engine": "`+string(engine)+`"}`, http.StatusOK)
+			var resp RunResponse
+			if err := json.Unmarshal([]byte(body), &resp); err != nil || describeRun(resp) != describeRun(tt.expected) {
+				t.Errorf("Incorrect response for %q with engine %s. Expected %s, received %s", tt.code, engine, describeRun(tt.expected), body)
+			}
+		}
+	}
+}
+
+func TestRunLargeValues(t *testing.T) {
+	opts := DefaultOptions()
+	s := New(opts)
+
+	//a is built of 2^25 ones, far more text than the output may hold
+	nested := `let a be [1] plz let i be 0 plz while (i < 25) please a be [a, a] plz i += 1 plz thanks `
+	for _, engine := range []interpreter.Engine{interpreter.EngineEval, interpreter.EngineVM} {
+		for _, code := range []string{nested + "a", nested + "print(a)"} {
+			resp := s.Run(context.Background(), code, engine)
+			if resp.Error != nil {
+				t.Errorf("Unexpected error for %q with engine %s: %s", code, engine, resp.Error.Message)
+				continue
+			}
+			text := resp.Output + resp.Result
+			if len(text) > opts.MaxOutput+len("Null...") || !strings.HasPrefix(text, "[[[") {
+				t.Errorf("Incorrect response for %q with engine %s: %d bytes starting %.20q", code, engine, len(text), text)
+			}
+		}
+	}
+}
+
+func TestRunRequestErrors(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MaxCodeSize = 100
+	s := New(opts)
+
+	tests := []struct {
+		body     string
+		status   int
+		expected string
+	}{
+		{`{"code": `, http.StatusBadRequest, "invalid request: unexpected EOF"},
+		{`{"code": "1", "engine": "jit"}`, http.StatusBadRequest, "unknown engine: jit"},
+		{`{"code": "` + strings.Repeat("1", 100) + `"}`, http.StatusRequestEntityTooLarge, "code is too long"},
+	}
+
+	for _, tt := range tests {
+		body := postRun(t, s, tt.body, tt.status)
+		var resp struct{ Message string }
+		if err := json.Unmarshal([]byte(body), &resp); err != nil || resp.Message != tt.expected {
+			t.Errorf("Incorrect error for %q. Expected %q, received %q", tt.body, tt.expected, body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/run", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /run returned status %d, expected %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestStaticFiles(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Static = http.Dir("../playground")
+	s := New(opts)
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/", http.StatusOK, "<title>PLZ playground</title>"},
+		{"/index.html", http.StatusOK, "<title>PLZ playground</title>"},
+		{"/examples.js", http.StatusOK, "helloWorld"},
+		{"/favicon.png", http.StatusOK, "PNG"},
+		{"/main.go", http.StatusNotFound, ""},
+		{"/favicon.ico", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("GET %s returned status %d, expected %d", tt.path, rec.Code, tt.status)
+		}
+		if !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("GET %s doesn't contain %q", tt.path, tt.body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	New(DefaultOptions()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("server without static files returned status %d for /", rec.Code)
+	}
+}
+
+func postRun(t *testing.T, s *Server, body string, status int) string {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/run", strings.NewReader(body)))
+	if rec.Code != status {
+		t.Errorf("POST /run %s returned status %d, expected %d: %s", body, rec.Code, status, rec.Body.String())
+	}
+	return rec.Body.String()
+}
+
+func quote(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+//describeRun shows every field of a response, for comparing them
+func describeRun(resp RunResponse) string {
+	described, _ := json.Marshal(resp)
+	return string(described)
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+//shared snippets: code POSTed to /save is stored under an id made from its hash, so saving the
+//same code twice gives the same id, and GET /load?id=... returns it
+
+//ErrSnippetNotFound is returned by a Store that has nothing saved under an id
+var ErrSnippetNotFound = errors.New("snippet not found")
+
+//Store keeps saved snippets. Implementations must be safe for concurrent use
+type Store interface {
+	Save(id string, code string) error
+	Load(id string) (string, error) //ErrSnippetNotFound if there is no snippet id
+}
+
+//snippetIDLength is the length of snippet ids: 11 characters of base64 hold 66 bits of the hash
+const snippetIDLength = 11
+
+//SnippetID is the id code is saved under: the start of its SHA-256 hash, in URL-safe base64
+func SnippetID(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return base64.RawURLEncoding.EncodeToString(sum[:])[:snippetIDLength]
+}
+
+//validSnippetID reports whether id could have come from SnippetID, so that ids from requests
+//are safe to use as file names
+func validSnippetID(id string) bool {
+	if len(id) != snippetIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+//MemoryStore keeps snippets in memory, losing them when the server stops. Once it holds as many
+//snippets or bytes of code as it may, saving another drops the oldest
+type MemoryStore struct {
+	maxSnippets int
+	maxBytes    int
+
+	mu       sync.RWMutex
+	snippets map[string]string
+	order    []string //ids in the order they were saved, oldest first
+	bytes    int
+}
+
+//NewMemoryStore keeps at most maxSnippets snippets holding at most maxBytes of code between
+//them. A limit of 0 means no limit
+func NewMemoryStore(maxSnippets int, maxBytes int) *MemoryStore {
+	return &MemoryStore{maxSnippets: maxSnippets, maxBytes: maxBytes, snippets: make(map[string]string)}
+}
+
+func (m *MemoryStore) Save(id string, code string) error {
+	if m.maxBytes > 0 && len(code) > m.maxBytes {
+		return errors.New("snippet is too large to keep")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.snippets[id]; ok {
+		return nil //ids come from the code, so it's already saved
+	}
+
+	for len(m.order) > 0 && (m.maxSnippets > 0 && len(m.order) >= m.maxSnippets || m.maxBytes > 0 && m.bytes+len(code) > m.maxBytes) {
+		oldest := m.order[0]
+		m.order = m.order[1:]
+		m.bytes -= len(m.snippets[oldest])
+		delete(m.snippets, oldest)
+	}
+
+	m.snippets[id] = code
+	m.order = append(m.order, id)
+	m.bytes += len(code)
+	return nil
+}
+
+func (m *MemoryStore) Load(id string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	code, ok := m.snippets[id]
+	if !ok {
+		return "", ErrSnippetNotFound
+	}
+	return code, nil
+}
+
+//FileStore keeps each snippet in a file named after its id in a directory
+type FileStore struct {
+	dir string
+}
+
+//NewFileStore stores snippets in dir, creating it if it doesn't exist
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (f *FileStore) path(id string) string {
+	return filepath.Join(f.dir, id+".plz")
+}
+
+//Save writes the snippet to a temporary file and renames it into place, so a snippet being
+//loaded while it is saved is never seen half written
+func (f *FileStore) Save(id string, code string) error {
+	if !validSnippetID(id) {
+		return errors.New("invalid snippet id: " + id)
+	}
+
+	tmp, err := ioutil.TempFile(f.dir, ".snippet-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //fails harmlessly once the file is renamed
+
+	if _, err := tmp.WriteString(code); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(id))
+}
+
+func (f *FileStore) Load(id string) (string, error) {
+	if !validSnippetID(id) {
+		return "", ErrSnippetNotFound
+	}
+
+	code, err := ioutil.ReadFile(f.path(id))
+	if os.IsNotExist(err) {
+		return "", ErrSnippetNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(code), nil
+}
+
+//SaveRequest is the JSON body POSTed to /save
+type SaveRequest struct {
+	Code string `json:"code"`
+}
+
+//SaveResponse is the reply from /save
+type SaveResponse struct {
+	ID string `json:"id"`
+}
+
+//LoadResponse is the reply from /load
+type LoadResponse struct {
+	Code string `json:"code"`
+}
+
+func (s *Server) handleSave(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Snippets == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req SaveRequest
+	if !s.readJSON(w, r, &req, "save a snippet") {
+		return
+	}
+
+	id := SnippetID(req.Code)
+	if err := s.opts.Snippets.Save(id, req.Code); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "could not save the snippet")
+		return
+	}
+	writeJSON(w, http.StatusOK, SaveResponse{ID: id})
+}
+
+func (s *Server) handleLoad(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Snippets == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, http.StatusMethodNotAllowed, "use GET to load a snippet")
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	code, err := "", ErrSnippetNotFound
+	if validSnippetID(id) { //stores only ever see ids that SnippetID could have made
+		code, err = s.opts.Snippets.Load(id)
+	}
+	if err == ErrSnippetNotFound {
+		writeJSONError(w, http.StatusNotFound, "no snippet with id "+id)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "could not load the snippet")
+		return
+	}
+	writeJSON(w, http.StatusOK, LoadResponse{Code: code})
+}
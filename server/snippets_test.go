@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSnippetID(t *testing.T) {
+	id := SnippetID(`print("hi")`)
+	if len(id) != snippetIDLength || !validSnippetID(id) {
+		t.Errorf("invalid id %q", id)
+	}
+	if SnippetID(`print("hi")`) != id {
+		t.Errorf("the same code has different ids")
+	}
+	if SnippetID(`print("bye")`) == id {
+		t.Errorf("different code has the same id")
+	}
+
+	for _, invalid := range []string{"", "short", "../../etc/pa", "abc/def.plz", id + "x"} {
+		if validSnippetID(invalid) {
+			t.Errorf("%q is a valid id", invalid)
+		}
+	}
+}
+
+func TestStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snippets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files, err := NewFileStore(dir + "/nested")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range map[string]Store{"memory": NewMemoryStore(0, 0), "file": files} {
+		code := "let x be 1 plz\nprint(x)"
+		id := SnippetID(code)
+
+		if _, err := store.Load(id); err != ErrSnippetNotFound {
+			t.Errorf("%s store: loading a missing snippet returned %v", name, err)
+		}
+		if err := store.Save(id, code); err != nil {
+			t.Fatalf("%s store: error saving: %s", name, err)
+		}
+		if err := store.Save(id, code); err != nil {
+			t.Fatalf("%s store: error saving again: %s", name, err)
+		}
+		loaded, err := store.Load(id)
+		if err != nil || loaded != code {
+			t.Errorf("%s store: loaded %q, %v", name, loaded, err)
+		}
+	}
+
+	if _, err := files.Load("../../etc/pa"); err != ErrSnippetNotFound {
+		t.Errorf("file store loaded an invalid id: %v", err)
+	}
+	if err := files.Save("../escape!!", "1"); err == nil {
+		t.Errorf("file store saved an invalid id")
+	}
+}
+
+func TestMemoryStoreLimits(t *testing.T) {
+	store := NewMemoryStore(3, 10)
+	save := func(code string) {
+		if err := store.Save(SnippetID(code), code); err != nil {
+			t.Fatalf("error saving %q: %s", code, err)
+		}
+	}
+
+	save("1")
+	save("2")
+	save("3")
+	save("1")        //already saved, so it stays the oldest
+	save("4")        //too many snippets, dropping 1
+	save("55555555") //too many snippets, dropping 2
+	save("66")       //too many snippets, dropping 3, then too many bytes, dropping 4
+
+	for code, kept := range map[string]bool{"1": false, "2": false, "3": false, "4": false, "55555555": true, "66": true} {
+		loaded, err := store.Load(SnippetID(code))
+		if kept && (err != nil || loaded != code) {
+			t.Errorf("snippet %q wasn't kept: %q, %v", code, loaded, err)
+		}
+		if !kept && err != ErrSnippetNotFound {
+			t.Errorf("snippet %q was kept", code)
+		}
+	}
+
+	if err := store.Save(SnippetID("12345678901"), "12345678901"); err == nil {
+		t.Errorf("saved a snippet larger than the store")
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Snippets = NewMemoryStore(0, 0)
+	s := New(opts)
+
+	code := `print("shared") plz`
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/save", strings.NewReader(`{"code": `+quote(code)+`}`)))
+	var saved SaveResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &saved); rec.Code != http.StatusOK || err != nil || saved.ID != SnippetID(code) {
+		t.Fatalf("Incorrect reply from /save: %d %s", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		method string
+		target string
+		status int
+		body   string
+	}{
+		{http.MethodGet, "/load?id=" + saved.ID, http.StatusOK, `{"code":"print(\"shared\") plz"}`},
+		{http.MethodGet, "/load?id=" + SnippetID("unsaved"), http.StatusNotFound, `{"message":"no snippet with id ` + SnippetID("unsaved") + `"}`},
+		{http.MethodGet, "/load?id=../secret", http.StatusNotFound, `{"message":"no snippet with id ../secret"}`},
+		{http.MethodPost, "/load?id=" + saved.ID, http.StatusMethodNotAllowed, `{"message":"use GET to load a snippet"}`},
+		{http.MethodGet, "/save", http.StatusMethodNotAllowed, `{"message":"use POST to save a snippet"}`},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.status || strings.TrimSpace(rec.Body.String()) != tt.body {
+			t.Errorf("%s %s returned %d %s, expected %d %s", tt.method, tt.target, rec.Code, rec.Body.String(), tt.status, tt.body)
+		}
+	}
+
+	//without a store, sharing is off
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/save", strings.NewReader(`{"code": "1"}`)),
+		httptest.NewRequest(http.MethodGet, "/load?id="+saved.ID, nil),
+	} {
+		rec := httptest.NewRecorder()
+		New(DefaultOptions()).ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s without a store returned %d", req.Method, req.URL, rec.Code)
+		}
+	}
+}
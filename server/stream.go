@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+//streaming runs: /stream runs the POSTed code like /run, but sends its output as server-sent
+//events while it runs. The events are
+//
+//	start      {"id": "..."}          the id to send to /cancel to stop the run
+//	output     {"text": "..."}        text printed by the program
+//	truncated  {}                     output went over the limit; the rest is dropped
+//	result     {"result": "..."}      the program finished; result is left out if it has no value
+//	error      RunError               the program didn't parse, raised an error or was cancelled
+//
+//The run is also cancelled if the client goes away
+
+//CancelRequest is the JSON body POSTed to /cancel
+type CancelRequest struct {
+	ID string `json:"id"`
+}
+
+//runs tracks the streaming runs in progress so they can be cancelled
+type runs struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+//start registers a run stopped by cancel, returning its id
+func (r *runs) start(cancel context.CancelFunc) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancels == nil {
+		r.cancels = make(map[string]context.CancelFunc)
+	}
+	r.cancels[id] = cancel
+	return id, nil
+}
+
+func (r *runs) finish(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cancels, id)
+}
+
+//cancel stops the run id, reporting false if there is no such run in progress
+func (r *runs) cancel(id string) bool {
+	r.mu.Lock()
+	cancel, ok := r.cancels[id]
+	r.mu.Unlock()
+
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	req, engine, ok := s.readRunRequest(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	id, err := s.runs.start(cancel)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "could not start the run")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") //keep proxies such as nginx from holding events back
+	w.WriteHeader(http.StatusOK)
+
+	events := &eventWriter{w: w, flusher: flusher}
+	events.send("start", map[string]string{"id": id})
+
+	out := &limitedWriter{w: outputEvents{events}, max: s.opts.MaxOutput}
+	out.onTruncate = func() { events.send("truncated", struct{}{}) }
+
+	result, runErr := s.run(ctx, req.Code, engine, out)
+	s.runs.finish(id) //before the final event, so a client seeing it can't cancel the run any more
+	if runErr != nil {
+		events.send("error", runErr)
+		return
+	}
+	events.send("result", struct {
+		Result string `json:"result,omitempty"`
+	}{result})
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use POST to cancel a run")
+		return
+	}
+
+	var req CancelRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if !s.runs.cancel(req.ID) {
+		writeJSONError(w, http.StatusNotFound, "no run in progress with id "+req.ID)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//eventWriter sends server-sent events, flushing each one so the client gets it straight away
+type eventWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (e *eventWriter) send(event string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", event, encoded); err != nil {
+		return err
+	}
+	e.flusher.Flush()
+	return nil
+}
+
+//outputEvents sends what the program prints as output events
+type outputEvents struct {
+	events *eventWriter
+}
+
+func (o outputEvents) Write(p []byte) (int, error) {
+	if err := o.events.send("output", map[string]string{"text": string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
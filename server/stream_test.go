@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type event struct {
+	name string
+	data string
+}
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected []event
+	}{
+		{`print("one") plz print("two") plz 1 + 2`, []event{
+			{"output", `{"text":"one\n"}`},
+			{"output", `{"text":"two\n"}`},
+			{"result", `{"result":"3"}`},
+		}},
+		{`let x be 1 plz`, []event{{"result", `{}`}}},
+		{`print("before") plz 1 + True`, []event{
+			{"output", `{"text":"before\n"}`},
+			{"error", `{"type":"TypeError","message":"type mismatch: INTEGER + BOOLEAN","traceback":"1:21: Error: type mismatch: INTEGER + BOOLEAN"}`},
+		}},
+		{`print("0123456789") plz print("0123456789")`, []event{
+			{"output", `{"text":"0123456789\n"}`},
+			{"output", `{"text":"01234"}`},
+			{"truncated", `{}`},
+			{"result", `{"result":"Null"}`},
+		}},
+	}
+
+	opts := DefaultOptions()
+	opts.MaxOutput = 16
+	ts := httptest.NewServer(New(opts))
+	defer ts.Close()
+
+	for _, tt := range tests {
+		resp, _, events := startStream(t, ts, tt.code)
+		for _, expected := range tt.expected {
+			got := <-events
+			if got != expected {
+				t.Errorf("Incorrect event for %q. Expected %v, received %v", tt.code, expected, got)
+			}
+		}
+		if extra, ok := <-events; ok {
+			t.Errorf("Unexpected event for %q: %v", tt.code, extra)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Limits.MaxSteps = 0
+	opts.Timeout = 0
+	ts := httptest.NewServer(New(opts))
+	defer ts.Close()
+
+	resp, id, events := startStream(t, ts, `print("started") plz while (True) please 1 thanks`)
+	defer resp.Body.Close()
+
+	//the output arrives while the program is still running
+	if got := <-events; got != (event{"output", `{"text":"started\n"}`}) {
+		t.Fatalf("Incorrect first event %v", got)
+	}
+
+	cancel, err := http.Post(ts.URL+"/cancel", "application/json", strings.NewReader(`{"id": "`+id+`"}`))
+	if err != nil {
+		t.Fatalf("error cancelling: %s", err)
+	}
+	cancel.Body.Close()
+	if cancel.StatusCode != http.StatusNoContent {
+		t.Errorf("cancel returned status %d, expected %d", cancel.StatusCode, http.StatusNoContent)
+	}
+
+	var runErr RunError
+	got := <-events
+	if err := json.Unmarshal([]byte(got.data), &runErr); got.name != "error" || err != nil || runErr.Message != "execution cancelled" {
+		t.Errorf("Incorrect event after cancelling: %v", got)
+	}
+
+	//the run is over, so there is nothing left to cancel
+	again, err := http.Post(ts.URL+"/cancel", "application/json", strings.NewReader(`{"id": "`+id+`"}`))
+	if err != nil {
+		t.Fatalf("error cancelling: %s", err)
+	}
+	again.Body.Close()
+	if again.StatusCode != http.StatusNotFound {
+		t.Errorf("cancelling a finished run returned status %d, expected %d", again.StatusCode, http.StatusNotFound)
+	}
+}
+
+//startStream POSTs code to /stream, returning the id from its start event and the events after it
+func startStream(t *testing.T, ts *httptest.Server, code string) (*http.Response, string, <-chan event) {
+	resp, err := http.Post(ts.URL+"/stream", "application/json", strings.NewReader(`{"code": `+quote(code)+`}`))
+	if err != nil {
+		t.Fatalf("error starting stream: %s", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream returned status %d and content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	all := readEvents(resp)
+	start := <-all
+	var started struct{ ID string }
+	if err := json.Unmarshal([]byte(start.data), &started); start.name != "start" || err != nil || started.ID == "" {
+		t.Fatalf("Incorrect start event %v", start)
+	}
+	return resp, started.ID, all
+}
+
+//readEvents parses the server-sent events in the body of resp until it ends
+func readEvents(resp *http.Response) <-chan event {
+	events := make(chan event)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var current event
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				current.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				current.data = strings.TrimPrefix(line, "data: ")
+			case line == "":
+				events <- current
+				current = event{}
+			}
+		}
+	}()
+	return events
+}
@@ -1,15 +1,45 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position //position of the first character of the token
+	End     Position //position just past the last character of the token
+}
+
+//Position is a location in PLZ source. Line and Column are 1-based; a zero Line means the position is unknown
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+//String formats the position as file:line:col, or line:col when no filename is known
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" //only produced when the lexer is asked to keep comments
 
 	//identifiers
 	IDENT  = "IDENT"
@@ -18,14 +48,19 @@ const (
 	STRING = "STRING"
 
 	//operators
-	ASSIGN      = "BE"
-	PLUS        = "+"
-	MINUS       = "-"
-	ASTERISK    = "*"
-	SLASH       = "/"
-	EXCLAMATION = "!"
-	COLON       = ":"
-	PERIOD      = "."
+	ASSIGN          = "BE"
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	PLUS            = "+"
+	MINUS           = "-"
+	ASTERISK        = "*"
+	SLASH           = "/"
+	EXCLAMATION     = "!"
+	COLON           = ":"
+	PERIOD          = "."
+	ELLIPSIS        = "..."
 
 	//comparison
 	LT     = "<"
@@ -52,6 +87,13 @@ const (
 	RETURN   = "RETURN"
 	IF       = "IF"
 	ELSE     = "ELSE"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	TRY      = "TRY"
+	SORRY    = "SORRY"
 )
 
 var keywords = map[string]TokenType{
@@ -66,6 +108,13 @@ var keywords = map[string]TokenType{
 	"if":       IF,
 	"else":     ELSE,
 	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"try":      TRY,
+	"sorry":    SORRY,
 }
 
 func NewToken(tt TokenType, literal byte) Token {
@@ -80,3 +129,60 @@ func LookupIdent(identifier string) TokenType {
 	}
 	return IDENT
 }
+
+//descriptions of token types as a PLZ programmer would write them, used in diagnostics
+var descriptions = map[TokenType]string{
+	ILLEGAL:    "illegal character",
+	EOF:        "end of input",
+	COMMENT:    "comment",
+	IDENT:      "identifier",
+	INT:        "integer",
+	FLOAT:      "float",
+	STRING:     "string",
+	ASSIGN:     "'be'",
+	TERMINATOR: "'plz'",
+	LBRACE:     "'please'",
+	RBRACE:     "'thanks'",
+	FUNCTION:   "'function'",
+	LET:        "'let'",
+	TRUE:       "'True'",
+	FALSE:      "'False'",
+	RETURN:     "'return'",
+	IF:         "'if'",
+	ELSE:       "'else'",
+	WHILE:      "'while'",
+	FOR:        "'for'",
+	IN:         "'in'",
+	BREAK:      "'break'",
+	CONTINUE:   "'continue'",
+	TRY:        "'try'",
+	SORRY:      "'sorry'",
+}
+
+//Describe names a token type in PLZ terms, e.g. "'thanks'" for RBRACE
+func Describe(tt TokenType) string {
+	desc, ok := descriptions[tt]
+	if ok {
+		return desc
+	}
+	return "'" + string(tt) + "'"
+}
+
+//Describe names a concrete token as it appeared in the source, e.g. "identifier 'foo'" or "'{'"
+func (t Token) Describe() string {
+	switch t.Type {
+	case EOF:
+		return Describe(EOF)
+	case ILLEGAL:
+		if t.Literal == "/*" {
+			return "unterminated comment"
+		}
+		return fmt.Sprintf("%s '%s'", Describe(t.Type), t.Literal)
+	case IDENT, INT, FLOAT:
+		return fmt.Sprintf("%s '%s'", Describe(t.Type), t.Literal)
+	case STRING:
+		return fmt.Sprintf("string %q", t.Literal)
+	default:
+		return "'" + t.Literal + "'"
+	}
+}
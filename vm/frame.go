@@ -0,0 +1,33 @@
+package vm
+
+import (
+	"github.com/MYKatz/PLZ/code"
+	"github.com/MYKatz/PLZ/object"
+)
+
+type Frame struct {
+	cl          *object.Closure
+	ip          int //offset of the next instruction to read
+	start       int //offset of the instruction being executed, for error positions
+	basePointer int //stack slot of the first local
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+func (f *Frame) readUint16() uint16 {
+	value := code.ReadUint16(f.Instructions()[f.ip:])
+	f.ip += 2
+	return value
+}
+
+func (f *Frame) readUint8() uint8 {
+	value := code.ReadUint8(f.Instructions()[f.ip:])
+	f.ip++
+	return value
+}
@@ -0,0 +1,647 @@
+//stack based virtual machine running bytecode from the compiler package
+package vm
+
+import (
+	"fmt"
+
+	"github.com/MYKatz/PLZ/code"
+	"github.com/MYKatz/PLZ/compiler"
+	"github.com/MYKatz/PLZ/evaluator"
+	"github.com/MYKatz/PLZ/object"
+)
+
+const (
+	StackSize   = 1 << 16
+	GlobalsSize = 1 << 16
+)
+
+//initialFrames is how many call frames a vm starts with room for. It makes room for more as
+//calls nest, up to its budget's MaxDepth
+const initialFrames = 1 << 6
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int //points to the next free slot; the top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+	handlers    []handler //open try expressions, innermost last
+
+	budget *object.Budget
+}
+
+//handler records where to resume when an error is raised inside a try expression
+type handler struct {
+	framesIndex int
+	sp          int
+	ip          int
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobals(bytecode, make([]object.Object, GlobalsSize))
+}
+
+//NewWithGlobals creates a vm sharing globals with earlier runs, or with values set up front
+func NewWithGlobals(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	mainClosure := &object.Closure{Fn: bytecode.Main}
+
+	frames := make([]*Frame, initialFrames)
+	frames[0] = NewFrame(mainClosure, 0)
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     globals,
+		globalNames: bytecode.GlobalNames,
+		stack:       make([]object.Object, StackSize),
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+//SetBudget limits the steps, call depth, allocations and time the program may use
+func (vm *VM) SetBudget(budget *object.Budget) {
+	vm.budget = budget
+
+	//every call uses a slot of the stack, so no more frames than that can be needed
+	if depth := budget.MaxDepth(); depth > 0 && depth < StackSize && depth+1 > len(vm.frames) {
+		frames := make([]*Frame, depth+1)
+		copy(frames, vm.frames)
+		vm.frames = frames
+	}
+}
+
+//Run executes the program, returning the value it evaluates to or the *object.Error that stopped it,
+//exactly as evaluator.Eval would
+func (vm *VM) Run() object.Object {
+	for {
+		result, err := vm.run()
+		if err == nil {
+			return result
+		}
+
+		frame := vm.currentFrame()
+		if !err.Pos.IsValid() {
+			err.Pos = frame.cl.Fn.PositionAt(frame.start)
+		}
+		if !vm.catch(err) {
+			err.Stack = vm.stackTrace()
+			return err
+		}
+	}
+}
+
+//Call calls fn, a closure or builtin from a program run with this vm's constants and globals,
+//with args, returning what it returns or the *object.Error it raised. It starts the vm afresh,
+//so it must not be used while Run is running
+func (vm *VM) Call(fn object.Object, args ...object.Object) object.Object {
+	if len(args) > 255 {
+		return newError("too many arguments: %d", len(args))
+	}
+
+	//a stand-in main function that only makes the call
+	caller := &object.CompiledFunction{
+		Instructions: append(code.Make(code.OpCall, len(args)), code.Make(code.OpReturnValue)...),
+	}
+	vm.frames[0] = NewFrame(&object.Closure{Fn: caller}, 0)
+	vm.framesIndex = 1
+	vm.handlers = nil
+
+	vm.stack[0] = fn
+	copy(vm.stack[1:], args)
+	vm.sp = len(args) + 1
+
+	return vm.Run()
+}
+
+//catch hands err to the innermost open try expression, reporting false if there is none
+func (vm *VM) catch(err *object.Error) bool {
+	if len(vm.handlers) == 0 || !err.Catchable() {
+		return false
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	for vm.framesIndex > h.framesIndex {
+		vm.popFrame()
+		vm.budget.Leave()
+	}
+	vm.sp = h.sp
+	vm.currentFrame().ip = h.ip
+
+	return vm.push(evaluator.CaughtError(err)) == nil
+}
+
+func (vm *VM) run() (object.Object, *object.Error) {
+	for {
+		frame := vm.currentFrame()
+		ins := frame.Instructions()
+		if frame.ip >= len(ins) {
+			return nil, newError("unexpected end of bytecode")
+		}
+
+		if err := vm.budget.Step(); err != nil {
+			return nil, err
+		}
+
+		frame.start = frame.ip
+		op := code.Opcode(ins[frame.ip])
+		frame.ip++
+
+		switch op {
+		case code.OpConstant:
+			index := frame.readUint16()
+			if err := vm.push(vm.constants[index]); err != nil {
+				return nil, err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpDup:
+			if err := vm.push(vm.stack[vm.sp-1]); err != nil {
+				return nil, err
+			}
+
+		case code.OpNull:
+			if err := vm.push(evaluator.NULL); err != nil {
+				return nil, err
+			}
+
+		case code.OpNil:
+			if err := vm.push(nil); err != nil {
+				return nil, err
+			}
+
+		case code.OpTrue:
+			if err := vm.push(evaluator.BOOL_TRUE); err != nil {
+				return nil, err
+			}
+
+		case code.OpFalse:
+			if err := vm.push(evaluator.BOOL_FALSE); err != nil {
+				return nil, err
+			}
+
+		case code.OpInfix:
+			operator := code.Operators[frame.readUint8()]
+			right := vm.pop()
+			left := vm.pop()
+			if err := vm.pushResult(executeInfix(operator, left, right)); err != nil {
+				return nil, err
+			}
+
+		case code.OpPrefix:
+			operator := code.Operators[frame.readUint8()]
+			right := vm.pop()
+			if err := vm.pushResult(evaluator.EvalPrefix(operator, orNull(right))); err != nil {
+				return nil, err
+			}
+
+		case code.OpCompound:
+			operator := code.Operators[frame.readUint8()]
+			current := vm.pop()
+			value := vm.pop()
+			if operator != "=" {
+				operator += "=" //ApplyAssignOperator takes the assignment operator
+			}
+			if err := vm.pushResult(evaluator.ApplyAssignOperator(operator, orNull(current), orNull(value))); err != nil {
+				return nil, err
+			}
+
+		case code.OpJump:
+			frame.ip = int(frame.readUint16())
+
+		case code.OpJumpNotTruthy:
+			target := int(frame.readUint16())
+			if !evaluator.IsTruthy(vm.pop()) {
+				frame.ip = target
+			}
+
+		case code.OpGetGlobal:
+			index := frame.readUint16()
+			value := vm.globals[index]
+			if value == nil {
+				return nil, newError("identifier not found: %s", vm.globalName(int(index)))
+			}
+			if err := vm.push(value); err != nil {
+				return nil, err
+			}
+
+		case code.OpSetGlobal:
+			vm.globals[frame.readUint16()] = vm.pop()
+
+		case code.OpGetLocal:
+			index := int(frame.readUint8())
+			value := vm.stack[frame.basePointer+index]
+			if value == nil {
+				return nil, newError("identifier not found: %s", frame.cl.Fn.LocalNames[index])
+			}
+			if err := vm.push(value); err != nil {
+				return nil, err
+			}
+
+		case code.OpSetLocal:
+			vm.stack[frame.basePointer+int(frame.readUint8())] = vm.pop()
+
+		case code.OpGetCell:
+			index := int(frame.readUint8())
+			value := vm.cell(frame, index).Value
+			if value == nil {
+				return nil, newError("identifier not found: %s", frame.cl.Fn.LocalNames[index])
+			}
+			if err := vm.push(value); err != nil {
+				return nil, err
+			}
+
+		case code.OpSetCell:
+			vm.cell(frame, int(frame.readUint8())).Value = vm.pop()
+
+		case code.OpLoadCell:
+			if err := vm.push(vm.cell(frame, int(frame.readUint8()))); err != nil {
+				return nil, err
+			}
+
+		case code.OpBoxLocal:
+			slot := frame.basePointer + int(frame.readUint8())
+			vm.stack[slot] = &object.Cell{Value: vm.stack[slot]}
+
+		case code.OpDefault:
+			value := vm.stack[frame.basePointer+int(frame.readUint8())]
+			target := int(frame.readUint16())
+			if cell, ok := value.(*object.Cell); ok {
+				value = cell.Value
+			}
+			if value != nil {
+				frame.ip = target
+			}
+
+		case code.OpGetFree:
+			index := int(frame.readUint8())
+			value := frame.cl.Free[index].Value
+			if value == nil {
+				return nil, newError("identifier not found: %s", frame.cl.Fn.FreeNames[index])
+			}
+			if err := vm.push(value); err != nil {
+				return nil, err
+			}
+
+		case code.OpSetFree:
+			frame.cl.Free[frame.readUint8()].Value = vm.pop()
+
+		case code.OpLoadFree:
+			if err := vm.push(frame.cl.Free[frame.readUint8()]); err != nil {
+				return nil, err
+			}
+
+		case code.OpRequireDefined:
+			scope := int(frame.readUint8())
+			index := int(frame.readUint16())
+			if err := vm.requireDefined(frame, scope, index); err != nil {
+				return nil, err
+			}
+
+		case code.OpArray:
+			n := int(frame.readUint16())
+			elements := make([]object.Object, n)
+			copy(elements, vm.stack[vm.sp-n:vm.sp])
+			vm.sp -= n
+			if err := vm.pushResult(&object.Array{Elements: elements}); err != nil {
+				return nil, err
+			}
+
+		case code.OpHash:
+			n := int(frame.readUint16())
+			hash, err := vm.buildHash(vm.sp-n, vm.sp)
+			if err != nil {
+				return nil, err
+			}
+			vm.sp -= n
+			if err := vm.pushResult(hash); err != nil {
+				return nil, err
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			if err := vm.pushResult(evaluator.EvalIndex(orNull(left), orNull(index))); err != nil {
+				return nil, err
+			}
+
+		case code.OpSetIndex:
+			operator := code.Operators[frame.readUint8()]
+			if operator != "=" {
+				operator += "="
+			}
+			index := vm.pop()
+			container := vm.pop()
+			value := vm.pop()
+			if err := vm.pushResult(evaluator.AssignIndex(operator, orNull(container), orNull(index), orNull(value))); err != nil {
+				return nil, err
+			}
+
+		case code.OpCall:
+			numArgs := int(frame.readUint8())
+			if err := vm.call(numArgs); err != nil {
+				return nil, err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.framesIndex == 1 {
+				return returnValue, nil
+			}
+
+			for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].framesIndex == vm.framesIndex {
+				vm.handlers = vm.handlers[:len(vm.handlers)-1] //returning from inside a try
+			}
+			returning := vm.popFrame()
+			vm.budget.Leave()
+			vm.sp = returning.basePointer - 1
+			if err := vm.push(returnValue); err != nil {
+				return nil, err
+			}
+
+		case code.OpClosure:
+			index := frame.readUint16()
+			numFree := int(frame.readUint8())
+
+			fn, ok := vm.constants[index].(*object.CompiledFunction)
+			if !ok {
+				return nil, newError("not a function: %+v", vm.constants[index])
+			}
+			free := make([]*object.Cell, numFree)
+			for i := 0; i < numFree; i++ {
+				free[i] = vm.stack[vm.sp-numFree+i].(*object.Cell)
+			}
+			vm.sp -= numFree
+			if err := vm.push(&object.Closure{Fn: fn, Free: free}); err != nil {
+				return nil, err
+			}
+
+		case code.OpIter:
+			items, err := evaluator.IterationItems(orNull(vm.pop()))
+			if err != nil {
+				return nil, err
+			}
+			if err := vm.push(&iterator{items: items}); err != nil {
+				return nil, err
+			}
+
+		case code.OpIterNext:
+			done := int(frame.readUint16())
+			iter := vm.stack[vm.sp-1].(*iterator)
+			if iter.next >= len(iter.items) {
+				vm.pop()
+				frame.ip = done
+				continue
+			}
+			item := iter.items[iter.next]
+			iter.next++
+			if err := vm.push(item); err != nil {
+				return nil, err
+			}
+
+		case code.OpTry:
+			target := int(frame.readUint16())
+			vm.handlers = append(vm.handlers, handler{framesIndex: vm.framesIndex, sp: vm.sp, ip: target})
+
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpError:
+			message := vm.constants[frame.readUint16()]
+			return nil, newError("%s", message.(*object.String).Value)
+
+		default:
+			return nil, newError("unknown opcode %d", op)
+		}
+	}
+}
+
+//stackTrace lists the running function calls, innermost first
+func (vm *VM) stackTrace() []object.StackFrame {
+	stack := []object.StackFrame{}
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		caller := vm.frames[i-1]
+		stack = append(stack, object.StackFrame{
+			Function: object.FunctionName(vm.frames[i].cl.Fn.Name),
+			CallSite: caller.cl.Fn.PositionAt(caller.start),
+		})
+	}
+	return stack
+}
+
+func (vm *VM) call(numArgs int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	if ho, ok := callee.(*object.HashObject); ok {
+		callee = ho.Inner
+	}
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		fn := callee.Fn
+		if err := evaluator.CheckArity(fn.NumRequired, fn.NumParameters, fn.Variadic, numArgs); err != nil {
+			return err
+		}
+		basePointer := vm.sp - numArgs
+
+		var rest *object.Array
+		if fn.Variadic {
+			rest = &object.Array{Elements: []object.Object{}}
+			if numArgs > fn.NumParameters {
+				rest.Elements = append(rest.Elements, vm.stack[basePointer+fn.NumParameters:vm.sp]...)
+				vm.sp = basePointer + fn.NumParameters
+			}
+		}
+
+		if basePointer+fn.NumLocals >= StackSize {
+			return stackOverflow()
+		}
+		if err := vm.budget.Enter(); err != nil {
+			return err
+		}
+		for i := vm.sp; i < basePointer+fn.NumLocals; i++ {
+			vm.stack[i] = nil //locals, and parameters left for their defaults, start out undefined
+		}
+		if rest != nil {
+			vm.stack[basePointer+fn.NumParameters] = rest
+		}
+
+		vm.pushFrame(NewFrame(callee, basePointer))
+		vm.sp = basePointer + fn.NumLocals
+		return nil
+
+	case *object.BuiltIn:
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		vm.sp -= numArgs + 1
+		return vm.pushResult(evaluator.ApplyBuiltin(callee, args))
+
+	default:
+		return newError("not a function: %s", orNull(callee).Type())
+	}
+}
+
+func (vm *VM) requireDefined(frame *Frame, scope int, index int) *object.Error {
+	var value object.Object
+	var name string
+
+	switch scope {
+	case code.ScopeGlobal:
+		value, name = vm.globals[index], vm.globalName(index)
+	case code.ScopeLocal:
+		value, name = vm.stack[frame.basePointer+index], frame.cl.Fn.LocalNames[index]
+	case code.ScopeCell:
+		value, name = vm.cell(frame, index).Value, frame.cl.Fn.LocalNames[index]
+	case code.ScopeFree:
+		value, name = frame.cl.Free[index].Value, frame.cl.Fn.FreeNames[index]
+	}
+
+	if value == nil {
+		return newError("cannot assign to undefined variable: %s", name)
+	}
+	return nil
+}
+
+func (vm *VM) buildHash(start int, end int) (object.Object, *object.Error) {
+	hash := object.NewHashMap()
+
+	for i := start; i < end; i += 2 {
+		key := orNull(vm.stack[i])
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, newError("not hashable: %s", key.Type())
+		}
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) cell(frame *Frame, index int) *object.Cell {
+	return vm.stack[frame.basePointer+index].(*object.Cell)
+}
+
+func (vm *VM) globalName(index int) string {
+	if index < len(vm.globalNames) {
+		return vm.globalNames[index]
+	}
+	return fmt.Sprintf("global %d", index)
+}
+
+func (vm *VM) push(obj object.Object) *object.Error {
+	if vm.sp >= StackSize {
+		return stackOverflow()
+	}
+
+	vm.stack[vm.sp] = obj
+	vm.sp++
+	return nil
+}
+
+//pushResult pushes the result of an operation, or returns it if it failed or is too large
+func (vm *VM) pushResult(obj object.Object) *object.Error {
+	if err, ok := obj.(*object.Error); ok {
+		return err
+	}
+	if err := vm.budget.CheckAllocation(obj); err != nil {
+		return err
+	}
+	return vm.push(obj)
+}
+
+func (vm *VM) pop() object.Object {
+	obj := vm.stack[vm.sp-1]
+	vm.sp--
+	return obj
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+//executeInfix handles integer arithmetic directly and defers everything else to the evaluator
+func executeInfix(operator string, left object.Object, right object.Object) object.Object {
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if lok && rok {
+		switch operator {
+		case "+":
+			return &object.Integer{Value: l.Value + r.Value}
+		case "-":
+			return &object.Integer{Value: l.Value - r.Value}
+		case "*":
+			return &object.Integer{Value: l.Value * r.Value}
+		case "<":
+			return nativeBool(l.Value < r.Value)
+		case ">":
+			return nativeBool(l.Value > r.Value)
+		case "==":
+			return nativeBool(l.Value == r.Value)
+		case "!=":
+			return nativeBool(l.Value != r.Value)
+		}
+	}
+	return evaluator.EvalInfix(operator, orNull(left), orNull(right))
+}
+
+func nativeBool(value bool) *object.Boolean {
+	if value {
+		return evaluator.BOOL_TRUE
+	}
+	return evaluator.BOOL_FALSE
+}
+
+//orNull stands in NULL for the missing value of a statement such as let, where the evaluator
+//would have nothing to operate on
+func orNull(obj object.Object) object.Object {
+	if obj == nil {
+		return evaluator.NULL
+	}
+	return obj
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+//stackOverflow is raised when the stack is full. Like going over a limit, try can't catch it
+func stackOverflow() *object.Error {
+	return &object.Error{Message: "stack overflow", Kind: object.LIMIT_ERROR}
+}
+
+//iterator walks the items of a for loop; it only ever lives on the vm's stack
+
+type iterator struct {
+	items []object.Object
+	next  int
+}
+
+func (it *iterator) Inspect() string {
+	return fmt.Sprintf("iterator(%d/%d)", it.next, len(it.items))
+}
+
+func (it *iterator) Type() string {
+	return "ITERATOR"
+}
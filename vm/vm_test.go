@@ -0,0 +1,329 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MYKatz/PLZ/compiler"
+	"github.com/MYKatz/PLZ/evaluator"
+	"github.com/MYKatz/PLZ/lexer"
+	"github.com/MYKatz/PLZ/object"
+	"github.com/MYKatz/PLZ/parser"
+)
+
+//inputs from evaluator_test.go, which both engines must agree on
+var evaluatorCases = []string{
+	"5", "10", "-5", "--10", "2 + 2 + 2", "5 - 3", "5 + 7 * 2", "50 / 2 * 2 + 10", "2 * (1 + 3)",
+	"False", "True", "5 == 5", "3 > 10", "3 < 10", "5 != 5", "7 != 2", "True == True", "True == False",
+	"(3 > 10) != False", "True == (5 == 5)",
+	"!True", "!False", "!5", "!!True", "!!False", "!!5",
+	"if (True) please 1 thanks", "if (10) please 10 thanks", "if (False) { 50 }", "if (10 > 2) { 25 }",
+	"if (10 < 2) { 10 }", "if (10 < 2) { 1 } else { 5 }",
+	"return 10 plz", "return 15 plz", "return 2 + 8 plz 9 plz",
+	"5 + True plz", "5 + True plz 5 plz", "-True plz", "True + False plz", "foo plz",
+	"let a be 5 plz let a be 5 plz a plz", "let a be 5 * 5 plz a plz", "let a be 5 plz let b be a plz b plz",
+	"let a be 5 plz let b be a plz let c be a + b + 5 plz c plz",
+	"function(x, y) please x + y plz thanks plz",
+	"let id be function(x) please x plz thanks plz id(5) plz",
+	"let id be function(x) please return x plz thanks plz id(5) plz",
+	"let double be function(x) please return x*2 plz thanks plz double(double(2))",
+	"function(x) please return x+1 plz thanks(5) plz",
+	`"Foo bar"`, `"Foo" + " " + "bar"`,
+	`len("foobar")`, `len("foo bar")`, `len("")`, `len(1)`, `len("foo", "bar")`,
+	"let len be function(x) please return x thanks plz",
+	"[1, 2 + 2, 3 * 3]", "[1, 2, 3][1]",
+	`let two be "two" plz
+	{
+		"one": 5 - 4,
+		two: 2,
+		"thre" + "e": 9 / 3,
+		4: 4,
+		True: 100,
+		False: -1
+	}`,
+	"let a be [1, 2, 4] plz let a be assign(a, 2, 3) plz a",
+	`let a be {"one": 1, "two": 3} plz let a be assign(a, "two", 2) plz a`,
+	"let a be [1, 2, 3] plz let r be rest(a) plz assign(r, 0, 9) plz a", "assign([1, 2], 2, 3)",
+	"first([])", "peek([])", "rest([])", "1 / 0",
+	"let a be append(append(append([], 1), 2), 3) plz let b be append(a, 9) plz let c be append(a, 10) plz b[0] be 100 plz [a, b, c]",
+	"let a be append(append([], 1), 2) plz let b be append(a, 3) plz a[0] be 7 plz assign(b, 1, 8) plz let c be append(a, 4) plz [a, b, c]",
+	`let m be {"one": 1, "two": 3} plz m["two"] = 2 plz m`,
+	`let m be {"one": 1, "two": 2} plz m.two plz`,
+	"let a be 5 plz\nlet b be a + True plz",
+	"2.5", "-1.5", "1e2", "1.5 + 1", "1 + 1.5", "3 / 2.0", "0.5 * 4", "10 - 0.25", "float(3)", `float("2.5")`,
+	"round(3.14159, 2)",
+	"1 == 1.0", "1.5 != 1", "2 < 2.5", "2.5 > 3", "0.1 + 0.2 > 0.3",
+	"int(3.9)", "int(-3.9)", `int("42")`, "round(2.5)", "round(-2.5)", "round(7)", `int("4.2")`, `float("pi")`,
+	`round("1")`, "int(1, 2)",
+	`let h be {1: "one", 2.5: "two and a half"} plz [h[1.0], h[2.5]]`,
+	"let i be 0 plz while (i < 5) please let i be i + 1 plz thanks i",
+	"let i be 0 plz while (True) please let i be i + 1 plz if (i == 3) please break plz thanks thanks i",
+	`let i be 0 plz let s be 0 plz
+	while (i < 5) please
+		let i be i + 1 plz
+		if (i == 2) please continue plz thanks
+		let s be s + i plz
+	thanks
+	s`,
+	"let f be function() please while (True) please return 7 plz thanks thanks plz f()",
+	"let s be 0 plz for x in [1, 2, 3] please let s be s + x plz thanks s",
+	`let n be 0 plz for c in "héllo" please let n be n + 1 plz thanks n`,
+	`let s be 0 plz for k in {1: "a", 2: "b", 3: "c"} please let s be s + k plz thanks s`,
+	"let s be 0 plz for x in [1, 2, 3, 4] please if (x == 2) please continue thanks if (x == 4) please break thanks let s be s + x plz thanks s",
+	"for x in [] please 1 + True thanks", "for x in 5 please x thanks", "for x in [1] please x + True thanks",
+	`let nums be [] plz
+	let i be 0 plz
+	while (i < 100000) please
+		let nums be append(nums, i) plz
+		let i be i + 1 plz
+	thanks
+	let sum be 0 plz
+	for n in nums please let sum be sum + n plz thanks
+	sum`,
+	"let x be 1 plz x be x + 1 plz x", "let x be 1 plz x = 5 plz x",
+	"let x be 1 plz x += 4 plz x -= 1 plz x *= 3 plz x /= 2 plz x",
+	"let a be 1 plz let b be 1 plz a be b be 7 plz a + b",
+	`let counter be function() please
+		let count be 0 plz
+		return function() please count += 1 plz return count plz thanks plz
+	thanks plz
+	let next be counter() plz
+	next() plz next() plz next()`,
+	"let x be 1 plz let f be function() please let x be 10 plz x += 1 plz thanks plz f() plz x",
+	"let arr be [1, 2, 3] plz arr[1] += 10 plz arr[1] be arr[1] * 2 plz arr[1]",
+	`let m be {"n": 1} plz m["n"] += 1 plz m.n *= 5 plz m["n"]`,
+	`let m be {} plz m["new"] be 3 plz m["new"]`,
+	`let h be {"z": 1, "a": 2, "m": 3} plz h["a"] be 5 plz h["b"] be 6 plz h`,
+	`let keys be [] plz for k in {"z": 1, 2: 2, "a": 3} please keys be append(keys, k) plz thanks keys`,
+	`let log be [] plz
+	let note be function(x) please log be append(log, x) plz x thanks plz
+	{note("b"): note(1), note("a"): note(2)} plz
+	log`,
+	`"a" == "a"`,
+	"[1, 2] == [1, 2]",
+	"[] == []",
+	`{"a": 1} == {"a": 2}`,
+	"let n be if (False) please 1 thanks plz n == if (False) please 2 thanks",
+	`1 != "1"`,
+	`"" == False`,
+	`let h be {"a": [1, 2]} plz h["a"] == [1, 2]`,
+	`"abc" > "ab"`,
+	"[1, 2] < [1, 3]",
+	"[1, 2] < [1, 2]",
+	"[[1, 2]] > [[1, 1, 9]]",
+	`[1, "a"] < [1, 2]`,
+	`"a" < 1`,
+	`{"a": 1} < {"a": 2}`,
+	`"a" - "b"`,
+	`let h be {"s": "abc"} plz len(h["s"])`, `let h be {"len": len} plz h.len("ab")`,
+	`let h be {"f": function(x) please x * 2 thanks} plz h.f(2)`,
+	"y be 1 plz", "let arr be [1] plz arr[3] be 1 plz", "let x be 1 plz x += True plz", `let s be "a" plz s[0] be "b" plz`,
+	"let f be function(a, b) please a + b thanks plz f(1)", "let f be function(a, b) please a + b thanks plz f(1, 2, 3)", "let f be function() please 1 thanks plz f(1)", "let f be function(a, b be 10) please a + b thanks plz f(1)", "let f be function(a, b be 10) please a + b thanks plz f(1, 2)", "let f be function(a, b be 10) please a + b thanks plz f()", "let f be function(a be 1, b be a * 2) please a + b thanks plz f(5)", "let f be function(a be 1 + True) please a thanks plz f(2)",
+	"let f be function(a be 1 + True) please a thanks plz f()", "let f be function(first, ...others) please len(others) thanks plz f(1, 2, 3)", "let f be function(first, ...others) please len(others) thanks plz f(1)", "let f be function(first, ...others) please others[1] thanks plz f(1, 2, 3)", "let f be function(first, ...others) please first thanks plz f()", "let f be function(a be 5, ...others) please a + len(others) thanks plz f()", "let f be function(...all) please let g be function() please len(all) thanks plz g() thanks plz f(1, 2)", "let f be function(a, n be function() please a thanks) please n() thanks plz f(7)",
+}
+
+//programs exercising closures and scoping, where the compiler has the most to get right
+var closureCases = []string{
+	`let fib be function(n) please if (n < 2) please return n thanks fib(n - 1) + fib(n - 2) thanks plz fib(15)`,
+	`let adder be function(a) please function(b) please a + b thanks thanks plz adder(2)(3)`,
+	`let outer be function() please
+		let f be function() please x thanks plz
+		let x be 5 plz
+		f()
+	thanks plz outer()`,
+	`let make be function() please
+		let fact be function(n) please if (n == 0) please 1 thanks else please n * fact(n - 1) thanks thanks plz
+		fact(5)
+	thanks plz make()`,
+	`let nest be function(a) please function(b) please function(c) please a + b + c thanks thanks thanks plz nest(1)(2)(3)`,
+	`let fs be [] plz
+	for i in [1, 2, 3] please let fs be append(fs, function() please i thanks) plz thanks
+	[fs[0](), fs[2]()]`,
+	`let g be function() please total thanks plz let total be 9 plz g()`,
+	`let f be function(a, b) please a + b thanks plz f(1, 2, 3)`,
+	`let f be function() please let y be 1 plz thanks plz f()`,
+	`let f be function() please missing thanks plz f()`,
+	`let f be function() please x = 1 plz thanks plz f()`,
+	`let s be 0 plz let add be function(n) please s += n plz thanks plz add(2) plz add(3) plz s`,
+	`let f be function(n) please let r be 0 plz for x in n please let r be r + x plz if (r > 3) please return r thanks thanks r thanks plz f([1, 2, 3, 4])`,
+	`let f be function() please 1 plz thanks plz f() + 1`,
+	`len`,
+	`5(1)`,
+	`let inner be function(x) please x + True thanks plz
+	let outer be function(y) please inner(y) thanks plz
+	outer(1)`,
+	`let apply be function(f) please f() thanks plz apply(function() please len(1) thanks)`,
+}
+
+//try expressions unwind the vm's frames and stack, so they get their own cases
+var tryCases = []string{
+	"try please 1 thanks sorry please 2 thanks",
+	"try please 1 + True thanks sorry (err) please err thanks",
+	"[1, 2, try please 1 + True thanks sorry (err) please err.type thanks, 4]",
+	`let f be function() please complain("deep") plz 1 thanks plz
+	let g be function() please f() + 1 thanks plz
+	[try please g() thanks sorry (err) please err.message thanks, 5]`,
+	`let f be function() please
+		try please return 1 thanks sorry please 2 thanks plz
+		3
+	thanks plz [f(), try please 1 + True thanks sorry please "caught after return" thanks]`,
+	`let n be 0 plz
+	for x in [1, 2, 3] please
+		try please if (x == 2) please break thanks let n be n + x plz thanks sorry please 0 thanks
+	thanks
+	[n, try please complain("after break") thanks sorry (err) please err.message thanks]`,
+	`let n be 0 plz
+	while (n < 3) please
+		n += 1 plz
+		try please if (n == 2) please continue thanks thanks sorry please 0 thanks
+	thanks
+	[n, try please complain("after continue") thanks sorry (err) please err.message thanks]`,
+	`try please
+		try please complain("inner") thanks sorry (err) please complain(err.message + " again") thanks
+	thanks sorry (err) please err.message thanks`,
+	`let f be function() please
+		let handled be try please for x in [1, 2] please x + True thanks thanks sorry (err) please err.type thanks plz
+		let later be function() please handled thanks plz
+		later()
+	thanks plz f()`,
+	`try please complain("a", "KeyError") thanks sorry (err) please complain(err) thanks`,
+	`let f be function() please complain("uncaught") thanks plz f()`,
+	`{[1]: 2}`,
+}
+
+func TestEnginesAgree(t *testing.T) {
+	inputs := append(append(append([]string{}, evaluatorCases...), closureCases...), tryCases...)
+
+	for _, input := range inputs {
+		expected := describe(evalProgram(t, input))
+		received := describe(runProgram(t, input))
+
+		if expected != received {
+			t.Errorf("engines disagree on %q.\n evaluator: %s\n vm:        %s", input, expected, received)
+		}
+	}
+}
+
+func TestRecursionDepth(t *testing.T) {
+	input := `let f be function(n) please if (n == 0) please 0 thanks else please 1 + f(n - 1) thanks thanks plz f(1000)`
+
+	result, ok := runProgram(t, input).(*object.Integer)
+	if !ok || result.Value != 1000 {
+		t.Fatalf("incorrect result, received %+v", result)
+	}
+
+	input = `let f be function() please f() thanks plz f()`
+	err, ok := runProgram(t, input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned for unbounded recursion")
+	}
+	//without a depth limit, recursion stops once the stack is full
+	if err.Message != "stack overflow" || err.Catchable() {
+		t.Errorf("wrong error, received %q", err.Message)
+	}
+}
+
+func TestWrongNumberOfArguments(t *testing.T) {
+	input := `let f be function(a, b) please a thanks plz f(1)`
+
+	err, ok := runProgram(t, input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+	expected := "wrong number of arguments: expected 2, received 1"
+	if err.Message != expected {
+		t.Errorf("wrong error message. Expected %q, received %q", expected, err.Message)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a be 5 plz\nlet b be a + True plz", "script.plz:2:10: Error: type mismatch: INTEGER + BOOLEAN"},
+		{"let f be function() please\n  missing\nthanks plz\nf()", "script.plz:2:3: Error: identifier not found: missing"},
+		{"let x be 1 plz\nlen(x)", "script.plz:2:1: Error: Invalid argument to len, received INTEGER"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewFileLexer("script.plz", tt.input)
+		p := parser.NewParser(l)
+		program := p.ParseProgram()
+
+		c := compiler.New()
+		if err := c.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		result := New(c.Bytecode()).Run()
+
+		err, ok := result.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. Received %T", tt.input, result)
+			continue
+		}
+		if err.Inspect() != tt.expected {
+			t.Errorf("wrong error output. Expected %q, received %q", tt.expected, err.Inspect())
+		}
+
+		evaluated := evaluator.Eval(program, object.NewEnvironment())
+		if evaluated.Inspect() != err.Inspect() {
+			t.Errorf("engines report different errors. Evaluator %q, vm %q", evaluated.Inspect(), err.Inspect())
+		}
+	}
+}
+
+func parse(input string) *parser.Parser {
+	return parser.NewParser(lexer.NewLexer(input))
+}
+
+func evalProgram(t *testing.T, input string) object.Object {
+	p := parse(input)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return evaluator.Eval(program, object.NewEnvironment())
+}
+
+func runProgram(t *testing.T, input string) object.Object {
+	p := parse(input)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compiler error for %q: %s", input, err)
+	}
+	return New(c.Bytecode()).Run()
+}
+
+//describe renders a result for comparison. Closures and functions both print as empty
+func describe(obj object.Object) string {
+	if obj == nil {
+		return "<no value>"
+	}
+
+	switch obj := obj.(type) {
+	case *object.HashMap:
+		pairs := []string{}
+		for _, pair := range obj.Items() {
+			pairs = append(pairs, describe(pair.Key)+": "+describe(pair.Value))
+		}
+		return "HASH{" + strings.Join(pairs, ", ") + "}"
+	case *object.Array:
+		elements := []string{}
+		for _, el := range obj.Elements {
+			elements = append(elements, describe(el))
+		}
+		return "ARRAY[" + strings.Join(elements, ", ") + "]"
+	case *object.HashObject:
+		return "HASHOBJ(" + describe(obj.Inner) + ")"
+	case *object.Error:
+		return obj.Traceback()
+	}
+
+	return obj.Type() + " " + obj.Inspect()
+}